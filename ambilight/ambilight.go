@@ -1,86 +1,680 @@
 package ambilight
 
 import (
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
+	"log/slog"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
 	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/bauersimon/ScreenToArtNet/smoothing"
 )
 
+// Source provides the measures of the screen areas, e.g. a *capture.Screen.
+type Source interface {
+	// Analyze returns the measures per screen area.
+	Analyze() ([]capture.Analysis, error)
+}
+
+// timedSource is a source reporting the durations of its phases, e.g. a *capture.Screen.
+type timedSource interface {
+	// LastDurations returns the time spent on the phases of the last analysis.
+	LastDurations() capture.Durations
+}
+
+// Frame holds the result of a single ambilight update.
+type Frame struct {
+	// Number holds the consecutive number of the update.
+	Number uint64
+	// Time holds the time of the update.
+	Time time.Time
+	// Colors holds the smoothed colors of the mapped screen areas.
+	Colors map[*image.Rectangle]color.RGBA64
+	// Devices holds the corrected colors of the mapped devices.
+	Devices map[*dmx.Device]color.RGBA64
+	// Mode holds the mode shown, either ModeAmbilight or the name of a preset.
+	Mode string
+	// Fade holds the progress between 0 and 1 of a crossfade to the mode, 1 if there is none.
+	Fade float64
+
+	// Captured holds whether the screen was captured for the frame, unset while paused or showing a preset.
+	Captured bool
+
+	// Sent holds the universes that were sent successfully.
+	Sent []*dmx.Universe
+	// SendErrors holds the number of universes that failed to send.
+	SendErrors int
+
+	// Capture holds the time spent capturing the screen, including the averaging if the source does not report it.
+	Capture time.Duration
+	// Average holds the time spent averaging the screen areas, if reported by the source.
+	Average time.Duration
+	// Send holds the time spent sending the DMX frames.
+	Send time.Duration
+}
+
+// Dropped returns whether not every universe received the frame.
+func (f *Frame) Dropped() bool {
+	return f.SendErrors > 0
+}
+
+// Stats holds the running statistics of an ambilight.
+type Stats struct {
+	// Frames holds the number of updates since the start.
+	Frames uint64
+	// Rate holds the recent number of updates per second.
+	Rate float64
+	// Running is set while the ambilight is started.
+	Running bool
+	// Paused is set while the ambilight is paused.
+	Paused bool
+	// Blackout is set while all channels are sent as zero.
+	Blackout bool
+	// Master holds the current master intensity between 0 and 1.
+	Master float64
+	// MasterTarget holds the master intensity between 0 and 1 the master is fading to.
+	MasterTarget float64
+	// Mode holds the mode, either ModeAmbilight or the name of a preset.
+	Mode string
+}
+
+// Option configures an ambilight.
+type Option func(a *Ambilight)
+
+// WithSleep sets the sleep time after each update.
+func WithSleep(sleep time.Duration) Option {
+	return func(a *Ambilight) {
+		a.sleep = sleep
+	}
+}
+
+// WithLogger sets the logger of the ambilight, which otherwise logs to the default logger.
+func WithLogger(logger *slog.Logger) Option {
+	return func(a *Ambilight) {
+		a.logger = logger
+	}
+}
+
+// WithFrameHandler registers a function that is called with every update.
+// The handler is called from the update loop and should return quickly.
+func WithFrameHandler(handler func(frame *Frame)) Option {
+	return func(a *Ambilight) {
+		a.handlers = append(a.handlers, handler)
+	}
+}
+
+// WithFrameChannel sends every update to the given channel, dropping updates while the channel is full.
+func WithFrameChannel(frames chan<- *Frame) Option {
+	return WithFrameHandler(func(frame *Frame) {
+		select {
+		case frames <- frame:
+		default:
+		}
+	})
+}
+
 // Ambilight holds all the information of an ambilight.
 type Ambilight struct {
-	// Controller holds the ArtNet controller.
-	Controller *dmx.ArtNetController
-	// Screen holds the screen configuration.
-	Screen *capture.Screen
-	// Universes hods the DMX universes.
-	Universes []*dmx.Universe
-	// Mappings holds the screen area to DMX devices mapping.
-	Mappings Mapping
+	// sender sends the DMX frames of the universes.
+	sender dmx.Sender
+
+	// sleep holds the sleep time after each update.
+	sleep time.Duration
+	// handlers holds the functions called with every update.
+	handlers []func(frame *Frame)
+	// logger holds the logger.
+	logger *slog.Logger
+	// master holds the master intensity all universes are scaled with.
+	master *dmx.Master
+
+	// lock guards the fields below.
+	lock sync.Mutex
+	// stats holds the running statistics.
+	stats Stats
+
+	// source holds the provider of the screen measures.
+	source Source
+	// universes hods the DMX universes.
+	universes []*dmx.Universe
+	// mappings holds the screen area to DMX devices mapping.
+	mappings Mapping
+	// filters holds the smoothing filters of the screen areas.
+	filters Filters
+	// corrections holds the color corrections of the DMX devices.
+	corrections Corrections
+	// devices holds the DMX devices per name.
+	devices map[string]*dmx.Device
+	// overrides holds the static colors of the DMX devices that do not follow the screen.
+	overrides map[*dmx.Device]color.RGBA64
+	// presets holds the presets per name.
+	presets map[string]*Preset
+	// transition holds the running crossfade, if any.
+	transition *transition
+	// output holds the last frame sent per universe.
+	output map[*dmx.Universe]dmx.DMXFrame
+
+	// stop is closed to stop the update loop.
+	stop chan struct{}
+	// done receives the result of the update loop.
+	done chan error
+	// wake wakes up a paused update loop.
+	wake chan struct{}
+}
+
+// New returns a new ambilight capturing from the source and sending over the sender as described by the configuration.
+func New(source Source, sender dmx.Sender, config *Configuration, options ...Option) *Ambilight {
+	a := &Ambilight{
+		sender: sender,
+		master: dmx.NewMaster(),
+
+		stats: Stats{
+			Mode: ModeAmbilight,
+		},
+
+		wake:   make(chan struct{}, 1),
+		logger: slog.Default(),
+	}
+	a.configure(source, config)
+
+	for _, option := range options {
+		option(a)
+	}
+
+	return a
+}
+
+// configure replaces the source and configuration, dropping all overrides and crossfades.
+// The ambilight falls back to ModeAmbilight if the preset shown is gone.
+func (a *Ambilight) configure(source Source, config *Configuration) {
+	a.source = source
+	a.universes = config.Universes
+	a.mappings = config.Mapping
+	a.filters = config.Filters
+	a.corrections = config.Corrections
+
+	a.devices = map[string]*dmx.Device{}
+	for d, name := range config.DeviceNames {
+		a.devices[name] = d
+	}
+	a.overrides = map[*dmx.Device]color.RGBA64{}
 
-	Config AmbilightConfiguration
+	a.presets = config.Presets
+	if _, ok := a.presets[a.stats.Mode]; !ok {
+		a.stats.Mode = ModeAmbilight
+	}
+	a.transition = nil
+	a.output = map[*dmx.Universe]dmx.DMXFrame{}
 }
 
-// AmbilightConfiguration holds the configuration for the ambilight.
-type AmbilightConfiguration struct {
-	// Sleep holds the sleep time after each update in ms.
-	Sleep int
+// Reconfigure replaces the source and configuration starting with the next update, dropping all overrides and crossfades.
+func (a *Ambilight) Reconfigure(source Source, config *Configuration) {
+	a.lock.Lock()
+	a.configure(source, config)
+	a.lock.Unlock()
+
+	a.logger.Info("ambilight reconfigured", "universes", len(config.Universes), "areas", len(config.Mapping))
 }
 
-// Go fires up the ambilight.
+// Start starts the update loop in the background.
+func (a *Ambilight) Start() error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if a.stats.Running {
+		return errors.New("ambilight already running")
+	}
+
+	a.stats.Running = true
+	a.stop = make(chan struct{})
+	a.done = make(chan error, 1)
+	go func(stop chan struct{}, done chan error) {
+		// Failures are returned to the caller of Stop or Wait, which reports them.
+		err := a.loop(stop)
+		if err == nil {
+			a.logger.Info("ambilight stopped")
+		}
+
+		a.lock.Lock()
+		a.stats.Running = false
+		a.lock.Unlock()
+
+		done <- err
+	}(a.stop, a.done)
+
+	a.logger.Info("ambilight started", "universes", len(a.universes), "areas", len(a.mappings), "sleep", a.sleep)
+
+	return nil
+}
+
+// Stop stops the update loop and returns the error it terminated with, if any.
+func (a *Ambilight) Stop() error {
+	a.lock.Lock()
+	stop, done := a.stop, a.done
+	if stop == nil {
+		a.lock.Unlock()
+
+		return errors.New("ambilight not started")
+	}
+	a.stop, a.done = nil, nil
+	a.lock.Unlock()
+
+	close(stop)
+
+	return <-done
+}
+
+// Wait blocks until the update loop terminates and returns the error it terminated with, if any.
+func (a *Ambilight) Wait() error {
+	a.lock.Lock()
+	done := a.done
+	a.lock.Unlock()
+
+	if done == nil {
+		return errors.New("ambilight not started")
+	}
+
+	err := <-done
+	done <- err // Keep the result for Stop.
+
+	return err
+}
+
+// Go fires up the ambilight and blocks until it terminates.
 func (a *Ambilight) Go() error {
-	// Data for the dynamic performance display.
-	iter := 0
-	maxIter := 10
+	if err := a.Start(); err != nil {
+		return err
+	}
+
+	return a.Wait()
+}
+
+// Pause suspends the screen capture until Resume is called.
+// Changes of the blackout, master, overrides and mode are still sent with the colors captured last.
+func (a *Ambilight) Pause() {
+	a.lock.Lock()
+	a.stats.Paused = true
+	a.lock.Unlock()
+
+	a.logger.Info("ambilight paused")
+}
+
+// Resume continues the updates after a Pause.
+func (a *Ambilight) Resume() {
+	a.lock.Lock()
+	a.stats.Paused = false
+	a.lock.Unlock()
+
+	a.logger.Info("ambilight resumed")
+	a.notify()
+}
+
+// SetBlackout sets whether all channels are sent as zero.
+func (a *Ambilight) SetBlackout(blackout bool) {
+	a.lock.Lock()
+	a.stats.Blackout = blackout
+	a.lock.Unlock()
+
+	a.logger.Info("blackout changed", "blackout", blackout)
+	a.notify()
+}
+
+// SetMaster sets the master intensity between 0 and 1 all channels are scaled with, unless excluded by their device.
+func (a *Ambilight) SetMaster(master float64) error {
+	return a.FadeMaster(master, 0)
+}
+
+// FadeMaster fades the master intensity to the given value between 0 and 1 over the given duration.
+func (a *Ambilight) FadeMaster(master float64, fade time.Duration) error {
+	if err := a.master.Set(master, fade, time.Now()); err != nil {
+		return err
+	}
+
+	a.logger.Info("master changed", "master", master, "fade", fade)
+	a.notify()
+
+	return nil
+}
+
+// SetOverride sets a static color for the named device instead of following the screen, or removes it if the color is nil.
+func (a *Ambilight) SetOverride(device string, c *color.RGBA64) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	d, ok := a.devices[device]
+	if !ok {
+		return fmt.Errorf("unknown device: %s", device)
+	}
+
+	if c == nil {
+		delete(a.overrides, d)
+		a.logger.Info("override removed", "device", device)
+	} else {
+		a.overrides[d] = *c
+		a.logger.Info("override set", "device", device, "color", fmt.Sprintf("%04x%04x%04x", c.R, c.G, c.B))
+	}
+	a.notify()
+
+	return nil
+}
+
+// SetMode crossfades over the given duration to the mode, either ModeAmbilight or the name of a preset.
+// Interrupting a crossfade continues from the current output.
+func (a *Ambilight) SetMode(mode string, fade time.Duration) error {
+	if fade < 0 {
+		return fmt.Errorf("invalid fade (fade=%v)", fade)
+	}
+
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	if _, ok := a.presets[mode]; !ok && mode != ModeAmbilight {
+		return fmt.Errorf("unknown mode: %s", mode)
+	}
+
+	now := time.Now()
+	t := &transition{
+		from:     a.stats.Mode,
+		start:    now,
+		duration: fade,
+	}
+	if a.transition != nil && a.transition.progress(now) < 1 {
+		t.frozen = map[*dmx.Universe]dmx.DMXFrame{}
+		for u, frame := range a.output {
+			t.frozen[u] = frame
+		}
+	}
+
+	a.stats.Mode = mode
+	a.transition = nil
+	if fade > 0 {
+		a.transition = t
+	}
+
+	a.logger.Info("mode changed", "mode", mode, "fade", fade)
+	a.notify()
+
+	return nil
+}
+
+// notify wakes up a paused update loop to send the changed controls.
+func (a *Ambilight) notify() {
+	select {
+	case a.wake <- struct{}{}:
+	default:
+	}
+}
+
+// fading returns whether the master or the mode is crossfading, so a paused update loop keeps sending.
+func (a *Ambilight) fading(now time.Time) bool {
+	a.lock.Lock()
+	transition := a.transition
+	a.lock.Unlock()
+
+	return a.master.Level(now) != a.master.Target() || (transition != nil && transition.progress(now) < 1)
+}
+
+// Modes returns ModeAmbilight followed by the names of the presets in alphabetical order.
+func (a *Ambilight) Modes() []string {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+
+	var presets []string
+	for name := range a.presets {
+		presets = append(presets, name)
+	}
+	sort.Strings(presets)
+
+	return append([]string{ModeAmbilight}, presets...)
+}
+
+// Stats returns the current running statistics.
+func (a *Ambilight) Stats() Stats {
+	a.lock.Lock()
+	stats := a.stats
+	a.lock.Unlock()
+
+	stats.Master = a.master.Level(time.Now())
+	stats.MasterTarget = a.master.Target()
+
+	return stats
+}
+
+func (a *Ambilight) loop(stop chan struct{}) error {
+	// Data for the update rate.
+	count := 0
 	start := time.Now()
 
 	for {
-		colors, err := a.Screen.GetColors()
-		if err != nil {
-			panic(err)
+		select {
+		case <-stop:
+			return nil
+		default:
 		}
 
-		for i, c := range colors {
-			devices, ok := a.Mappings[a.Screen.Areas[i]]
-			if !ok {
-				// This area has no devices mapped.
-				continue
+		if a.Stats().Paused {
+			// Keep the fixtures following the controls with the last captured colors.
+			if _, err := a.hold(time.Now()); err != nil {
+				return err
 			}
 
-			for _, d := range devices {
-				d.RValue = c.R
-				d.GValue = c.G
-				d.BValue = c.B
+			var tick <-chan time.Time
+			if a.fading(time.Now()) {
+				tick = time.After(fadeInterval)
 			}
+			select {
+			case <-stop:
+				return nil
+			case <-a.wake:
+			case <-tick:
+			}
+
+			count = 0
+			start = time.Now()
+
+			continue
 		}
 
-		for _, u := range a.Universes {
-			err := u.SendColorUpdate(a.Controller)
-			if err != nil {
-				return err
+		if _, err := a.update(time.Now()); err != nil {
+			return err
+		}
+
+		count++
+		if elapsed := time.Since(start); elapsed >= time.Second {
+			rate := float64(count) / elapsed.Seconds()
+			a.lock.Lock()
+			a.stats.Rate = rate
+			a.lock.Unlock()
+
+			a.logger.Debug("update rate", "updates_per_sec", rate)
+
+			count = 0
+			start = time.Now()
+		}
+
+		if a.sleep > 0 {
+			select {
+			case <-stop:
+				return nil
+			case <-time.After(a.sleep):
 			}
 		}
+	}
+}
+
+// fadeInterval holds the time between the frames sent for a crossfade while paused.
+const fadeInterval = 25 * time.Millisecond
 
-		if a.Config.Sleep > 0 {
-			time.Sleep(time.Duration(a.Config.Sleep) * time.Millisecond)
+// update performs a single capture, computes the device values and sends them.
+func (a *Ambilight) update(now time.Time) (*Frame, error) {
+	return a.send(now, true)
+}
+
+// hold sends the device values of the last capture with the current controls, without capturing.
+func (a *Ambilight) hold(now time.Time) (*Frame, error) {
+	return a.send(now, false)
+}
+
+// send computes the device values, following the screen if follow is set, and sends them.
+func (a *Ambilight) send(now time.Time, follow bool) (*Frame, error) {
+	frame := &Frame{
+		Time:    now,
+		Colors:  map[*image.Rectangle]color.RGBA64{},
+		Devices: map[*dmx.Device]color.RGBA64{},
+	}
+
+	a.lock.Lock()
+	source, universes, mappings, filters, corrections := a.source, a.universes, a.mappings, a.filters, a.corrections
+	blackout := a.stats.Blackout
+	overrides := make(map[*dmx.Device]color.RGBA64, len(a.overrides))
+	for d, c := range a.overrides {
+		overrides[d] = c
+	}
+	mode, presets, fade := a.stats.Mode, a.presets, a.transition
+	if fade != nil && fade.progress(now) >= 1 {
+		a.transition, fade = nil, nil
+	}
+	a.lock.Unlock()
+
+	frame.Mode = mode
+	frame.Fade = 1
+
+	// The screen is only captured while it is shown.
+	live := mode == ModeAmbilight || (fade != nil && fade.frozen == nil && fade.from == ModeAmbilight)
+	if live && follow {
+		if err := a.updateDevices(frame, source, mappings, filters, corrections, overrides); err != nil {
+			return nil, err
+		}
+		frame.Captured = true
+	} else if live {
+		for d, c := range overrides {
+			d.SetColor(c.R, c.G, c.B)
+			frame.Devices[d] = c
 		}
+	}
 
-		// Handle the performance display
-		if iter == maxIter {
-			diff := time.Since(start).Seconds()
-			updates := float64(iter) / diff
-			fmt.Printf("%.2f updates/sec\r", updates)
-			maxIter = int(updates) * 5 // We will perform roughly one print every 5 seconds.
+	master := a.master.Level(now)
+	state := func(mode string, u *dmx.Universe) dmx.DMXFrame {
+		if p, ok := presets[mode]; ok {
+			return p.frame(u, corrections, master)
+		}
 
-			iter = 0
-			start = time.Now()
-		} else {
-			iter++
+		return u.Frame(master)
+	}
+
+	output := map[*dmx.Universe]dmx.DMXFrame{}
+	sendStart := time.Now()
+	for _, u := range universes {
+		var out dmx.DMXFrame
+		if !blackout {
+			out = state(mode, u)
+			if fade != nil {
+				from, ok := fade.frozen[u]
+				if !ok && fade.frozen == nil {
+					from = state(fade.from, u)
+				}
+				frame.Fade = fade.progress(now)
+				out = blend(from, out, u.FineChannels(), frame.Fade)
+			}
+		}
+		output[u] = out
+
+		err := a.sender.SendDMX(out, u.Net, u.SubNet)
+		if err != nil {
+			// Keep going, the next frame might get through.
+			a.logger.Error("sending failed", "net", u.Net, "subnet", u.SubNet, "error", err)
+			frame.SendErrors++
+
+			continue
 		}
+		frame.Sent = append(frame.Sent, u)
 	}
+	frame.Send = time.Since(sendStart)
+
+	a.lock.Lock()
+	a.output = output
+	a.stats.Frames++
+	frame.Number = a.stats.Frames
+	a.lock.Unlock()
+
+	for _, handler := range a.handlers {
+		handler(frame)
+	}
+
+	return frame, nil
+}
+
+// updateDevices captures the screen and sets the device values following it.
+func (a *Ambilight) updateDevices(frame *Frame, source Source, mappings Mapping, filters Filters, corrections Corrections, overrides map[*dmx.Device]color.RGBA64) error {
+	now := frame.Time
+
+	analyses, err := source.Analyze()
+	if err != nil {
+		return fmt.Errorf("capturing failed: %v", err)
+	}
+	if t, ok := source.(timedSource); ok {
+		durations := t.LastDurations()
+		frame.Capture = durations.Capture
+		frame.Average = durations.Average
+	} else {
+		frame.Capture = time.Since(now)
+	}
+
+	for _, analysis := range analyses {
+		devices, ok := mappings[analysis.Area]
+		if !ok {
+			// This area has no devices mapped.
+			continue
+		}
+
+		c := analysis.Color
+		if f, ok := filters[analysis.Area]; ok {
+			c = f.Apply(c, now)
+		}
+		frame.Colors[analysis.Area] = c
+
+		measures := dmx.Measures{
+			Luma:      analysis.Luma,
+			Max:       analysis.Max,
+			CentroidX: analysis.CentroidX,
+			CentroidY: analysis.CentroidY,
+		}
+
+		for _, d := range devices {
+			d.UpdateDrivers(measures)
+
+			if _, ok := overrides[d]; ok {
+				continue
+			}
+
+			dc := c
+			if chain, ok := corrections[d]; ok {
+				dc = chain.Apply(correction.FromRGBA64(c)).RGBA64()
+			}
+
+			d.SetColor(dc.R, dc.G, dc.B)
+			frame.Devices[d] = dc
+		}
+	}
+
+	for d, c := range overrides {
+		d.SetColor(c.R, c.G, c.B)
+		frame.Devices[d] = c
+	}
+
+	return nil
 }
 
 // Mapping holds a mapping from screen areas to DMX devices.
 type Mapping map[*image.Rectangle][]*dmx.Device
+
+// Filters holds a mapping from screen areas to their smoothing filters.
+type Filters map[*image.Rectangle]*smoothing.Filter
+
+// Corrections holds a mapping from DMX devices to their color correction.
+type Corrections map[*dmx.Device]correction.Chain
@@ -0,0 +1,376 @@
+package ambilight
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeSource returns fixed analyses.
+type fakeSource struct {
+	analyses []capture.Analysis
+	err      error
+
+	// lock guards the number of calls to Analyze.
+	lock     sync.Mutex
+	analyzed int
+}
+
+func (s *fakeSource) Analyze() ([]capture.Analysis, error) {
+	s.lock.Lock()
+	s.analyzed++
+	s.lock.Unlock()
+
+	return s.analyses, s.err
+}
+
+// sentFrame holds a frame received by the fake sender.
+type sentFrame struct {
+	frame dmx.DMXFrame
+	net   uint8
+	sub   uint8
+}
+
+// fakeSender records the sent frames.
+type fakeSender struct {
+	lock   sync.Mutex
+	frames []sentFrame
+	err    error
+}
+
+func (s *fakeSender) SendDMX(frame dmx.DMXFrame, net uint8, sub uint8) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	s.frames = append(s.frames, sentFrame{frame: frame, net: net, sub: sub})
+
+	return nil
+}
+
+func (s *fakeSender) sent() []sentFrame {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return append([]sentFrame(nil), s.frames...)
+}
+
+func newTestAmbilight(options ...Option) (*Ambilight, *fakeSource, *fakeSender, *image.Rectangle, *dmx.Device) {
+	area := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	unmapped := &image.Rectangle{Max: image.Point{X: 5, Y: 5}}
+	device := &dmx.Device{R: 0, G: 1, B: 2}
+
+	source := &fakeSource{
+		analyses: []capture.Analysis{
+			{
+				Area:  area,
+				Color: color.RGBA64{R: 0xffff, G: 0x8000, B: 0, A: 0xffff},
+			},
+			{
+				Area:  unmapped,
+				Color: color.RGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff},
+			},
+		},
+	}
+	sink := &fakeSender{}
+
+	config := &Configuration{
+		Areas: []*image.Rectangle{area, unmapped},
+		Universes: []*dmx.Universe{
+			&dmx.Universe{
+				Devices: []*dmx.Device{device},
+				Net:     1,
+				SubNet:  2,
+			},
+		},
+		Mapping: Mapping{
+			area: []*dmx.Device{device},
+		},
+		Corrections: Corrections{
+			device: correction.Chain{correction.Gain{0.5, 1, 1}},
+		},
+		DeviceNames: map[*dmx.Device]string{
+			device: "par",
+		},
+	}
+
+	return New(source, sink, config, options...), source, sink, area, device
+}
+
+func TestAmbilightUpdate(t *testing.T) {
+	var handled []*Frame
+	a, _, sink, area, device := newTestAmbilight(WithFrameHandler(func(frame *Frame) {
+		handled = append(handled, frame)
+	}))
+
+	now := time.Unix(0, 0)
+	frame, err := a.update(now)
+	assert.NoError(t, err)
+
+	assert.Equal(t, uint64(1), frame.Number)
+	assert.Equal(t, now, frame.Time)
+	assert.Equal(t, map[*image.Rectangle]color.RGBA64{
+		area: color.RGBA64{R: 0xffff, G: 0x8000, B: 0, A: 0xffff},
+	}, frame.Colors)
+	assert.Equal(t, map[*dmx.Device]color.RGBA64{
+		device: color.RGBA64{R: 0x8000, G: 0x8000, B: 0, A: 0xffff},
+	}, frame.Devices)
+	assert.Equal(t, []*Frame{frame}, handled)
+	assert.True(t, frame.Captured)
+	assert.False(t, frame.Dropped())
+	assert.Len(t, frame.Sent, 1)
+
+	sent := sink.sent()
+	if assert.Len(t, sent, 1) {
+		assert.Equal(t, uint8(1), sent[0].net)
+		assert.Equal(t, uint8(2), sent[0].sub)
+		assert.Equal(t, dmx.DMXFrame{0x80, 0x80, 0}, sent[0].frame)
+	}
+
+	assert.Equal(t, uint64(1), a.Stats().Frames)
+}
+
+func TestAmbilightControls(t *testing.T) {
+	a, source, sink, _, device := newTestAmbilight()
+	now := time.Unix(0, 0)
+
+	assert.EqualError(t, a.SetMaster(1.5), "invalid master intensity (master=1.5)")
+	assert.NoError(t, a.SetMaster(0.5))
+	frame, err := a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA64{R: 0x8000, G: 0x8000, B: 0, A: 0xffff}, frame.Devices[device])
+	assert.Equal(t, dmx.DMXFrame{0x40, 0x40, 0}, sink.sent()[0].frame)
+
+	assert.EqualError(t, a.SetOverride("unknown", &color.RGBA64{}), "unknown device: unknown")
+	assert.NoError(t, a.SetOverride("par", &color.RGBA64{R: 0, G: 0, B: 0xffff, A: 0xffff}))
+	frame, err = a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA64{R: 0, G: 0, B: 0xffff, A: 0xffff}, frame.Devices[device])
+	assert.Equal(t, dmx.DMXFrame{0, 0, 0x80}, sink.sent()[1].frame)
+
+	a.SetBlackout(true)
+	_, err = a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, dmx.DMXFrame{}, sink.sent()[2].frame)
+	a.SetBlackout(false)
+
+	assert.NoError(t, a.SetOverride("par", nil))
+	assert.NoError(t, a.SetMaster(1))
+	_, err = a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, dmx.DMXFrame{0x80, 0x80, 0}, sink.sent()[3].frame)
+
+	stats := a.Stats()
+	assert.False(t, stats.Blackout)
+	assert.Equal(t, 1.0, stats.Master)
+
+	assert.NoError(t, a.FadeMaster(0, time.Hour))
+	stats = a.Stats()
+	assert.InDelta(t, 1.0, stats.Master, 0.01)
+	assert.Equal(t, 0.0, stats.MasterTarget)
+	assert.NoError(t, a.SetMaster(1))
+
+	other := &dmx.Device{R: 3, G: 4, B: 5}
+	a.Reconfigure(source, &Configuration{
+		Universes: []*dmx.Universe{
+			&dmx.Universe{
+				Devices: []*dmx.Device{other},
+			},
+		},
+		Mapping: Mapping{
+			source.analyses[0].Area: []*dmx.Device{other},
+		},
+		DeviceNames: map[*dmx.Device]string{
+			other: "other",
+		},
+	})
+	_, err = a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, dmx.DMXFrame{0, 0, 0, 0xff, 0x80, 0}, sink.sent()[4].frame)
+	assert.EqualError(t, a.SetOverride("par", nil), "unknown device: par")
+}
+
+func TestAmbilightModes(t *testing.T) {
+	a, source, sink, _, device := newTestAmbilight()
+	a.Reconfigure(source, &Configuration{
+		Universes: a.universes,
+		Mapping:   a.mappings,
+		Presets: map[string]*Preset{
+			"blue": &Preset{
+				Colors: map[*dmx.Device]color.RGBA64{
+					device: color.RGBA64{B: 0xffff, A: 0xffff},
+				},
+			},
+			"off": &Preset{},
+		},
+	})
+	assert.Equal(t, []string{"ambilight", "blue", "off"}, a.Modes())
+	assert.EqualError(t, a.SetMode("red", 0), "unknown mode: red")
+
+	// The live state is 0xff, 0x80, 0.
+	now := time.Now()
+	assert.NoError(t, a.SetMode("blue", time.Hour))
+	frame, err := a.update(now.Add(15 * time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, "blue", frame.Mode)
+	assert.InDelta(t, 0.25, frame.Fade, 0.01)
+	assert.Equal(t, dmx.DMXFrame{0xbf, 0x60, 0x40}, sink.sent()[0].frame)
+	assert.Len(t, frame.Colors, 1)
+	assert.True(t, frame.Captured)
+
+	// Interrupting continues from the current output without capturing the screen.
+	assert.NoError(t, a.SetMode("off", time.Hour))
+	frame, err = a.update(now.Add(30 * time.Minute))
+	assert.NoError(t, err)
+	assert.InDelta(t, 0.5, frame.Fade, 0.01)
+	assert.Equal(t, dmx.DMXFrame{0x60, 0x30, 0x20}, sink.sent()[1].frame)
+	assert.Empty(t, frame.Colors)
+	assert.False(t, frame.Captured)
+
+	assert.NoError(t, a.SetMode("blue", 0))
+	frame, err = a.update(now)
+	assert.NoError(t, err)
+	assert.Equal(t, 1.0, frame.Fade)
+	assert.Equal(t, dmx.DMXFrame{0, 0, 0xff}, sink.sent()[2].frame)
+	assert.Equal(t, "blue", a.Stats().Mode)
+
+	// Without the preset the ambilight falls back to following the screen.
+	a.Reconfigure(source, &Configuration{
+		Universes: a.universes,
+		Mapping:   a.mappings,
+	})
+	assert.Equal(t, ModeAmbilight, a.Stats().Mode)
+}
+
+func TestAmbilightLifecycle(t *testing.T) {
+	frames := make(chan *Frame, 1)
+	a, _, _, _, _ := newTestAmbilight(WithFrameChannel(frames), WithSleep(time.Millisecond))
+
+	assert.EqualError(t, a.Stop(), "ambilight not started")
+
+	assert.NoError(t, a.Start())
+	assert.EqualError(t, a.Start(), "ambilight already running")
+	assert.True(t, a.Stats().Running)
+
+	select {
+	case <-frames:
+	case <-time.After(time.Second):
+		t.Fatal("no frame received")
+	}
+
+	a.Pause()
+	assert.True(t, a.Stats().Paused)
+	a.Resume()
+	assert.False(t, a.Stats().Paused)
+
+	assert.NoError(t, a.Stop())
+	assert.False(t, a.Stats().Running)
+	assert.NotZero(t, a.Stats().Frames)
+}
+
+func TestAmbilightPausedControls(t *testing.T) {
+	a, source, sink, _, _ := newTestAmbilight()
+	last := func() dmx.DMXFrame {
+		sent := sink.sent()
+		if len(sent) == 0 {
+			return dmx.DMXFrame{0xff}
+		}
+
+		return sent[len(sent)-1].frame
+	}
+
+	a.Pause()
+	assert.NoError(t, a.Start())
+	defer a.Stop()
+
+	// The held frame follows the controls while the screen is not captured.
+	assert.Eventually(t, func() bool { return last() == dmx.DMXFrame{} }, time.Second, time.Millisecond)
+	assert.NoError(t, a.SetOverride("par", &color.RGBA64{B: 0xffff, A: 0xffff}))
+	assert.Eventually(t, func() bool { return last() == dmx.DMXFrame{0, 0, 0xff} }, time.Second, time.Millisecond)
+
+	a.SetBlackout(true)
+	assert.Eventually(t, func() bool { return last() == dmx.DMXFrame{} }, time.Second, time.Millisecond)
+
+	a.SetBlackout(false)
+	assert.NoError(t, a.SetMaster(0.5))
+	assert.Eventually(t, func() bool { return last() == dmx.DMXFrame{0, 0, 0x80} }, time.Second, time.Millisecond)
+
+	// Fades keep being sent without further changes.
+	assert.NoError(t, a.FadeMaster(0, 100*time.Millisecond))
+	assert.Eventually(t, func() bool { return last() == dmx.DMXFrame{} }, time.Second, time.Millisecond)
+
+	source.lock.Lock()
+	assert.Zero(t, source.analyzed)
+	source.lock.Unlock()
+}
+
+func TestAmbilightSourceError(t *testing.T) {
+	a, source, _, _, _ := newTestAmbilight()
+	source.err = errors.New("no screen")
+
+	var buffer bytes.Buffer
+	a.logger = slog.New(slog.NewTextHandler(&buffer, nil))
+
+	assert.EqualError(t, a.Go(), "capturing failed: no screen")
+	assert.EqualError(t, a.Stop(), "capturing failed: no screen")
+
+	log := buffer.String()
+	assert.Contains(t, log, "msg=\"ambilight started\" universes=1 areas=1")
+	assert.NotContains(t, log, "level=ERROR")
+}
+
+func TestAmbilightLogging(t *testing.T) {
+	var buffer bytes.Buffer
+	var lock sync.Mutex
+	dropped := 0
+	a, _, sink, _, _ := newTestAmbilight(WithLogger(slog.New(slog.NewTextHandler(&buffer, nil))), WithFrameHandler(func(frame *Frame) {
+		if frame.Dropped() {
+			lock.Lock()
+			dropped++
+			lock.Unlock()
+		}
+	}))
+	sink.err = errors.New("network unreachable")
+
+	// Failed sends are logged and the ambilight keeps running.
+	assert.NoError(t, a.Start())
+	assert.Eventually(t, func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+
+		return dropped > 1
+	}, time.Second, time.Millisecond)
+	assert.NoError(t, a.Stop())
+
+	log := buffer.String()
+	assert.Contains(t, log, "msg=\"ambilight started\" universes=1 areas=1")
+	assert.Contains(t, log, "msg=\"sending failed\" net=1 subnet=2 error=\"network unreachable\"")
+}
+
+func TestAmbilightDroppedFrame(t *testing.T) {
+	var dropped *Frame
+	a, _, sink, _, _ := newTestAmbilight(WithFrameHandler(func(frame *Frame) {
+		dropped = frame
+	}))
+	sink.err = errors.New("network unreachable")
+
+	frame, err := a.update(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, frame, dropped)
+	if assert.NotNil(t, dropped) {
+		assert.True(t, dropped.Dropped())
+		assert.Empty(t, dropped.Sent)
+	}
+}
@@ -0,0 +1,55 @@
+package ambilight
+
+import (
+	"fmt"
+)
+
+// stripAreas returns the names of the areas sampled by strips.
+func (r *rawConfig) stripAreas() map[string]bool {
+	areas := map[string]bool{}
+	for _, s := range r.Strips {
+		if s.Area != "" {
+			areas[s.Area] = true
+		}
+	}
+
+	return areas
+}
+
+// unused returns warnings about declared areas, devices and universes that are not used, ordered by their names.
+// Areas sampled by strips count as used.
+func (r *rawConfig) unused(stripAreas map[string]bool) (warnings []string) {
+	mappedDevices := map[string]bool{}
+	for _, deviceNames := range r.AreasToDevices {
+		for _, name := range deviceNames {
+			mappedDevices[name] = true
+		}
+	}
+	universeDevices := map[string]bool{}
+	for _, deviceNames := range r.UniversesToDevices {
+		for _, name := range deviceNames {
+			universeDevices[name] = true
+		}
+	}
+
+	for _, name := range sortedNames(r.Areas) {
+		if len(r.AreasToDevices[name]) == 0 && !stripAreas[name] {
+			warnings = append(warnings, fmt.Sprintf("area %s is not mapped to any device", name))
+		}
+	}
+	for _, name := range sortedNames(r.Devices) {
+		if !universeDevices[name] {
+			warnings = append(warnings, fmt.Sprintf("device %s is not part of any universe", name))
+		}
+		if !mappedDevices[name] {
+			warnings = append(warnings, fmt.Sprintf("device %s is not mapped to any area", name))
+		}
+	}
+	for _, name := range sortedNames(r.Universes) {
+		if len(r.UniversesToDevices[name]) == 0 {
+			warnings = append(warnings, fmt.Sprintf("universe %s has no devices", name))
+		}
+	}
+
+	return warnings
+}
@@ -0,0 +1,85 @@
+package ambilight
+
+import (
+	"image"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnused(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data       *rawConfig
+		StripAreas map[string]bool
+
+		Expected []string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Data.unused(tc.StripAreas))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Used",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"left":   &image.Rectangle{},
+				"screen": &image.Rectangle{},
+			},
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+			},
+			Devices: map[string]*rawDevice{
+				"par": &rawDevice{},
+			},
+			UniversesToDevices: map[string][]string{
+				"u1": []string{"par"},
+			},
+			AreasToDevices: map[string][]string{
+				"left": []string{"par"},
+			},
+		},
+		StripAreas: map[string]bool{
+			"screen": true,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unused",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"left":  &image.Rectangle{},
+				"right": &image.Rectangle{},
+			},
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+				"u2": &dmx.Universe{},
+			},
+			Devices: map[string]*rawDevice{
+				"bar":  &rawDevice{},
+				"par":  &rawDevice{},
+				"spot": &rawDevice{},
+			},
+			UniversesToDevices: map[string][]string{
+				"u1": []string{"par", "bar"},
+				"u2": []string{},
+			},
+			AreasToDevices: map[string][]string{
+				"left": []string{"par", "spot"},
+			},
+		},
+
+		Expected: []string{
+			"area right is not mapped to any device",
+			"device bar is not mapped to any area",
+			"device spot is not part of any universe",
+			"universe u2 has no devices",
+		},
+	})
+}
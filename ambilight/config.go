@@ -1,67 +1,436 @@
 package ambilight
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"image"
 	"io/ioutil"
 	"os"
-	"path"
+	"sort"
 
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
 	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/bauersimon/ScreenToArtNet/fixture"
+	"github.com/bauersimon/ScreenToArtNet/smoothing"
 )
 
+// Configuration holds a loaded ambilight configuration.
+// Areas and universes are ordered by their names.
+type Configuration struct {
+	// Areas holds the screen areas.
+	Areas []*image.Rectangle
+	// AreaNames holds the names of the screen areas.
+	AreaNames map[*image.Rectangle]string
+	// Universes holds the DMX universes.
+	Universes []*dmx.Universe
+	// UniverseNames holds the names of the DMX universes.
+	UniverseNames map[*dmx.Universe]string
+	// DeviceNames holds the names of the DMX devices.
+	DeviceNames map[*dmx.Device]string
+	// Mapping holds the screen area to DMX devices mapping.
+	Mapping Mapping
+	// Filters holds the smoothing filters of the screen areas.
+	Filters Filters
+	// Capture holds the capture settings overriding the defaults per screen area.
+	Capture map[*image.Rectangle]capture.AreaConfig
+	// Window holds the captured window the screen areas are relative to, nil captures the monitor.
+	Window *capture.WindowTarget
+	// Corrections holds the color corrections of the DMX devices.
+	Corrections Corrections
+	// Presets holds the presets per name.
+	Presets map[string]*Preset
+
+	// Warnings holds the problems of the configuration that do not prevent running it, e.g. unused devices.
+	Warnings []string
+}
+
+// Area returns the screen area with the given name.
+func (c *Configuration) Area(name string) (area *image.Rectangle, ok bool) {
+	for a, n := range c.AreaNames {
+		if n == name {
+			return a, true
+		}
+	}
+
+	return nil, false
+}
+
+// Universe returns the DMX universe with the given name.
+func (c *Configuration) Universe(name string) (universe *dmx.Universe, ok bool) {
+	for u, n := range c.UniverseNames {
+		if n == name {
+			return u, true
+		}
+	}
+
+	return nil, false
+}
+
+// Device returns the DMX device with the given name.
+func (c *Configuration) Device(name string) (device *dmx.Device, ok bool) {
+	for d, n := range c.DeviceNames {
+		if n == name {
+			return d, true
+		}
+	}
+
+	return nil, false
+}
+
 // rawConfig holds the complete raw configuration structure.
 type rawConfig struct {
+	// Include holds further configuration files merged into this one, relative to this file.
+	Include []string `json:",omitempty"`
+
 	// Areas holds area names and their respective areas.
-	Areas map[string]*image.Rectangle
+	Areas map[string]*image.Rectangle `json:",omitempty"`
 	// Universes hold universe names and their respective DMX universes.
-	Universes map[string]*dmx.Universe
+	Universes map[string]*dmx.Universe `json:",omitempty"`
 	// Devices hold device names and their respective devices.
-	Devices map[string]*dmx.Device
+	Devices map[string]*rawDevice `json:",omitempty"`
+	// Profiles holds the directory of the fixture profiles, relative to the configuration file declaring it.
+	Profiles string `json:",omitempty"`
 
 	// UniversesToDevices maps universe names to multiple device names.
-	UniversesToDevices map[string][]string
+	UniversesToDevices map[string][]string `json:",omitempty"`
 	// AreasToDevices maps area names to multiple device names.
-	AreasToDevices map[string][]string
+	AreasToDevices map[string][]string `json:",omitempty"`
+
+	// Smoothing maps area names to their smoothing configuration.
+	Smoothing map[string]*smoothing.Config `json:",omitempty"`
+	// DefaultSmoothing holds the smoothing configuration of the areas without their own.
+	DefaultSmoothing *smoothing.Config `json:",omitempty"`
+	// Capture maps area names to the capture settings overriding the run settings.
+	Capture map[string]*capture.AreaConfig `json:",omitempty"`
+	// Window holds the captured window the areas without their own monitor are relative to.
+	Window *capture.WindowTarget `json:",omitempty"`
+	// Corrections maps device names to their color correction.
+	Corrections map[string]*correction.Config `json:",omitempty"`
+
+	// Strips holds strip names and their respective pixel-mapped LED strips.
+	Strips map[string]*rawStrip `json:",omitempty"`
+
+	// Presets holds preset names and their respective static looks.
+	Presets map[string]*rawPreset `json:",omitempty"`
+
+	// Settings holds the run settings.
+	Settings *Settings `json:",omitempty"`
+}
+
+// rawDevice holds a device declared either with explicit channels or as an instance of a fixture profile.
+type rawDevice struct {
+	dmx.Device
+
+	// Profile holds the name of the fixture profile of the device.
+	Profile string `json:",omitempty"`
+	// Address holds the first channel of a device declared by a fixture profile.
+	Address uint16 `json:",omitempty"`
+
+	// explicit holds if the device declares color channels itself.
+	explicit bool
+	// footprint holds the number of channels occupied by the fixture profile of the device starting at its address.
+	footprint uint16
+}
+
+// channelKeys holds the keys of the color channels of a device.
+var channelKeys = []string{
+	"Red", "Green", "Blue", "White", "Amber", "UV", "ColdWhite", "WarmWhite",
+	"RedFine", "GreenFine", "BlueFine", "WhiteFine", "AmberFine", "UVFine", "ColdWhiteFine", "WarmWhiteFine",
 }
 
-// ReadConfig reads the given config file.
-func ReadConfig(configPath string) (areas []*image.Rectangle, universes []*dmx.Universe, mapping Mapping, err error) {
-	cwd, err := os.Getwd()
+// UnmarshalJSON decodes the device and remembers if it declares color channels itself.
+func (d *rawDevice) UnmarshalJSON(data []byte) error {
+	type plain rawDevice
+	if err := json.Unmarshal(data, (*plain)(d)); err != nil {
+		return err
+	}
+
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	for _, key := range channelKeys {
+		if _, ok := keys[key]; ok {
+			d.explicit = true
+
+			break
+		}
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the device, writing only the profile, the address and the overrides of a device declared by a fixture profile.
+func (d rawDevice) MarshalJSON() ([]byte, error) {
+	if d.Profile == "" || d.explicit {
+		type plain rawDevice
+
+		return json.Marshal(plain(d))
+	}
+
+	return json.Marshal(struct {
+		Profile       string
+		Address       uint16                 `json:",omitempty"`
+		Statics       map[uint16]uint8       `json:",omitempty"`
+		Drivers       map[uint16]*dmx.Driver `json:",omitempty"`
+		MasterExclude []uint16               `json:",omitempty"`
+		MasterInclude []uint16               `json:",omitempty"`
+	}{
+		Profile:       d.Profile,
+		Address:       d.Address,
+		Statics:       d.Statics,
+		Drivers:       d.Drivers,
+		MasterExclude: d.MasterExclude,
+		MasterInclude: d.MasterInclude,
+	})
+}
+
+// ReadConfig reads the given config file together with the files it includes.
+// Relative paths are searched in the working directory and the XDG configuration directories.
+func ReadConfig(configPath string) (*Configuration, error) {
+	configPath, err := resolveConfigPath(configPath)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
 	}
 
-	data, err := ioutil.ReadFile(path.Join(cwd, configPath))
+	raw, _, err := loadConfig(configPath)
 	if err != nil {
-		return nil, nil, nil, err
+		return nil, err
+	}
+
+	var profiles map[string]*fixture.Profile
+	if raw.Profiles != "" {
+		profiles, err = fixture.LoadProfiles(raw.Profiles)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := raw.expandProfiles(profiles); err != nil {
+		return nil, err
+	}
+	stripAreas := raw.stripAreas()
+	if err := raw.expandStrips(); err != nil {
+		return nil, err
+	}
+
+	config := &Configuration{
+		AreaNames:     map[*image.Rectangle]string{},
+		UniverseNames: map[*dmx.Universe]string{},
+		DeviceNames:   map[*dmx.Device]string{},
+	}
+
+	config.Mapping, err = raw.constructMapping()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range sortedNames(raw.Areas) {
+		a := raw.Areas[name]
+		config.Areas = append(config.Areas, a)
+		config.AreaNames[a] = name
+	}
+
+	config.Universes, err = raw.constructUniverses()
+	if err != nil {
+		return nil, err
+	}
+
+	for name, u := range raw.Universes {
+		config.UniverseNames[u] = name
+	}
+	for name, d := range raw.Devices {
+		config.DeviceNames[&d.Device] = name
+	}
+
+	config.Filters, err = raw.constructFilters()
+	if err != nil {
+		return nil, err
+	}
+
+	config.Capture, err = raw.constructCapture()
+	if err != nil {
+		return nil, err
+	}
+
+	if raw.Window != nil {
+		if err := raw.Window.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid window: %v", err)
+		}
+		config.Window = raw.Window
+	}
+
+	config.Corrections, err = raw.constructCorrections()
+	if err != nil {
+		return nil, err
+	}
+
+	config.Presets, err = raw.constructPresets()
+	if err != nil {
+		return nil, err
+	}
+
+	config.Warnings = raw.unused(stripAreas)
+
+	return config, nil
+}
+
+// ReadAreas reads the declared screen areas of the given config file and the files it includes.
+func ReadAreas(configPath string) (map[string]image.Rectangle, error) {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	areas := map[string]image.Rectangle{}
+	for name, a := range raw.Areas {
+		areas[name] = *a
+	}
+
+	return areas, nil
+}
+
+// WriteAreas replaces the declared screen areas in the JSON config files declaring them, keeping everything else.
+// New areas are added to the given config file.
+func WriteAreas(configPath string, areas map[string]image.Rectangle) error {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return err
+	}
+
+	_, declared, err := loadConfig(configPath)
+	if err != nil {
+		return err
+	}
+
+	files := map[string]map[string]image.Rectangle{}
+	for name, a := range areas {
+		file, ok := declared["Areas"][name]
+		if !ok {
+			file = configPath
+		}
+		if files[file] == nil {
+			files[file] = map[string]image.Rectangle{}
+		}
+		files[file][name] = a
+	}
+
+	for file, changed := range files {
+		if err := writeAreas(file, changed); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeAreas replaces the given screen areas of a single JSON config file, keeping everything else.
+func writeAreas(configPath string, changed map[string]image.Rectangle) error {
+	if f := formatOf(configPath); f != formatJSON {
+		return fmt.Errorf("writing areas is not supported for %s configurations", f)
+	}
+
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return err
 	}
 
 	raw, err := parseConfig(data)
 	if err != nil {
-		return nil, nil, nil, err
+		return err
+	}
+
+	areas := map[string]image.Rectangle{}
+	for name, a := range raw.Areas {
+		areas[name] = *a
+	}
+	for name, a := range changed {
+		areas[name] = a
 	}
 
-	mapping, err = raw.constructMapping()
+	rawAreas, err := json.Marshal(areas)
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
 
-	for _, a := range raw.Areas {
-		areas = append(areas, a)
+	data, err = replaceKey(data, "Areas", rawAreas)
+	if err != nil {
+		return err
 	}
 
-	universes, err = raw.constructUniverses()
+	info, err := os.Stat(configPath)
 	if err != nil {
-		return nil, nil, nil, err
+		return err
 	}
 
-	return areas, universes, mapping, nil
+	return ioutil.WriteFile(configPath, data, info.Mode())
+}
+
+// replaceKey replaces the value of a key of the given JSON object, keeping the order of the other keys.
+func replaceKey(data []byte, key string, value json.RawMessage) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if t, err := decoder.Token(); err != nil {
+		return nil, err
+	} else if t != json.Delim('{') {
+		return nil, fmt.Errorf("configuration is not a JSON object")
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('{')
+
+	replaced := false
+	for decoder.More() {
+		t, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		k := t.(string)
+
+		var v json.RawMessage
+		if err := decoder.Decode(&v); err != nil {
+			return nil, err
+		}
+		if k == key {
+			v = value
+			replaced = true
+		}
+
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(k)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(v)
+	}
+	if !replaced {
+		if buffer.Len() > 1 {
+			buffer.WriteByte(',')
+		}
+		name, _ := json.Marshal(key)
+		buffer.Write(name)
+		buffer.WriteByte(':')
+		buffer.Write(value)
+	}
+	buffer.WriteByte('}')
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, buffer.Bytes(), "", "\t"); err != nil {
+		return nil, err
+	}
+
+	return indented.Bytes(), nil
 }
 
 func (r *rawConfig) constructUniverses() (universes []*dmx.Universe, err error) {
-	for universeName, deviceNames := range r.UniversesToDevices {
+	for _, universeName := range sortedNames(r.UniversesToDevices) {
+		deviceNames := r.UniversesToDevices[universeName]
 		u, ok := r.Universes[universeName]
 		if !ok {
 			return nil, fmt.Errorf("unknown universe: %s", universeName)
@@ -71,6 +440,14 @@ func (r *rawConfig) constructUniverses() (universes []*dmx.Universe, err error)
 		if err != nil {
 			return nil, err
 		}
+
+		if err := u.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid universe %s: %v", universeName, err)
+		}
+		if err := r.checkOverlaps(universeName, deviceNames); err != nil {
+			return nil, err
+		}
+
 		universes = append(universes, u)
 	}
 
@@ -79,7 +456,8 @@ func (r *rawConfig) constructUniverses() (universes []*dmx.Universe, err error)
 
 func (r *rawConfig) constructMapping() (mapping Mapping, err error) {
 	mapping = make(Mapping)
-	for areaName, deviceNames := range r.AreasToDevices {
+	for _, areaName := range sortedNames(r.AreasToDevices) {
+		deviceNames := r.AreasToDevices[areaName]
 		a, ok := r.Areas[areaName]
 		if !ok {
 			return nil, fmt.Errorf("unknown area: %s", areaName)
@@ -94,6 +472,75 @@ func (r *rawConfig) constructMapping() (mapping Mapping, err error) {
 	return mapping, nil
 }
 
+func (r *rawConfig) constructFilters() (filters Filters, err error) {
+	filters = make(Filters)
+	for _, areaName := range sortedNames(r.Smoothing) {
+		config := r.Smoothing[areaName]
+		a, ok := r.Areas[areaName]
+		if !ok {
+			return nil, fmt.Errorf("unknown area: %s", areaName)
+		}
+
+		if err := config.Verify(); err != nil {
+			return nil, err
+		}
+
+		filters[a] = smoothing.NewFilter(*config)
+	}
+
+	if r.DefaultSmoothing != nil {
+		if err := r.DefaultSmoothing.Verify(); err != nil {
+			return nil, err
+		}
+
+		for _, a := range r.Areas {
+			if _, ok := filters[a]; !ok {
+				filters[a] = smoothing.NewFilter(*r.DefaultSmoothing)
+			}
+		}
+	}
+
+	return filters, nil
+}
+
+func (r *rawConfig) constructCapture() (configs map[*image.Rectangle]capture.AreaConfig, err error) {
+	configs = map[*image.Rectangle]capture.AreaConfig{}
+	for _, areaName := range sortedNames(r.Capture) {
+		config := r.Capture[areaName]
+		a, ok := r.Areas[areaName]
+		if !ok {
+			return nil, fmt.Errorf("unknown area: %s", areaName)
+		}
+
+		if err := config.Verify(); err != nil {
+			return nil, fmt.Errorf("invalid capture of area %s: %v", areaName, err)
+		}
+
+		configs[a] = *config
+	}
+
+	return configs, nil
+}
+
+func (r *rawConfig) constructCorrections() (corrections Corrections, err error) {
+	corrections = make(Corrections)
+	for _, deviceName := range sortedNames(r.Corrections) {
+		config := r.Corrections[deviceName]
+		d, ok := r.Devices[deviceName]
+		if !ok {
+			return nil, fmt.Errorf("unknown device: %s", deviceName)
+		}
+
+		if err := config.Verify(); err != nil {
+			return nil, err
+		}
+
+		corrections[&d.Device] = config.Chain()
+	}
+
+	return corrections, nil
+}
+
 func (r *rawConfig) getDevicesNamed(names []string) ([]*dmx.Device, error) {
 	devices := make([]*dmx.Device, len(names))
 	for i, deviceName := range names {
@@ -102,12 +549,103 @@ func (r *rawConfig) getDevicesNamed(names []string) ([]*dmx.Device, error) {
 			return nil, fmt.Errorf("unknown device: %s", deviceName)
 		}
 
-		devices[i] = d
+		devices[i] = &d.Device
 	}
 
 	return devices, nil
 }
 
+// expandProfiles replaces the devices declared by a fixture profile with the devices of their profile.
+// Statics declared with the device take precedence over the defaults of the profile, drivers and master exclusions are kept.
+func (r *rawConfig) expandProfiles(profiles map[string]*fixture.Profile) error {
+	for _, deviceName := range sortedNames(r.Devices) {
+		d := r.Devices[deviceName]
+		if d.Profile == "" {
+			continue
+		}
+
+		if d.explicit {
+			return fmt.Errorf("device %s declares both the profile %s and color channels", deviceName, d.Profile)
+		}
+		p, ok := profiles[d.Profile]
+		if !ok {
+			return fmt.Errorf("unknown profile %s of device %s", d.Profile, deviceName)
+		}
+
+		expanded, err := p.Device(d.Address)
+		if err != nil {
+			return fmt.Errorf("invalid device %s: %v", deviceName, err)
+		}
+		if int(d.Address)+int(p.Channels) > 512 {
+			return fmt.Errorf("invalid device %s: profile %s exceeds the DMX channels (address=%v, channels=%v)", deviceName, d.Profile, d.Address, p.Channels)
+		}
+		for channel, value := range d.Statics {
+			expanded.Statics[channel] = value
+		}
+		expanded.Drivers = d.Drivers
+		expanded.MasterExclude = append(expanded.MasterExclude, d.MasterExclude...)
+		expanded.MasterInclude = append(expanded.MasterInclude, d.MasterInclude...)
+
+		d.Device = *expanded
+		d.footprint = p.Channels
+	}
+
+	return nil
+}
+
+// checkOverlaps checks that the given devices of a universe do not share any channel.
+// Devices of fixture profiles occupy all channels of their profile, including the unused ones.
+func (r *rawConfig) checkOverlaps(universeName string, deviceNames []string) error {
+	users := map[uint16]string{}
+	for _, deviceName := range deviceNames {
+		for _, channel := range r.Devices[deviceName].occupied() {
+			if other, ok := users[channel]; ok {
+				return fmt.Errorf("devices %s and %s overlap in universe %s (channel=%v)", other, deviceName, universeName, channel)
+			}
+			users[channel] = deviceName
+		}
+	}
+
+	return nil
+}
+
+// occupied returns the channels used by the device and the footprint of its fixture profile in ascending order.
+func (d *rawDevice) occupied() []uint16 {
+	channels := d.Channels()
+	if d.footprint == 0 {
+		return channels
+	}
+
+	used := map[uint16]bool{}
+	for channel := d.Address; channel < d.Address+d.footprint; channel++ {
+		used[channel] = true
+	}
+	for _, channel := range channels {
+		used[channel] = true
+	}
+
+	occupied := make([]uint16, 0, len(used))
+	for channel := range used {
+		occupied = append(occupied, channel)
+	}
+	sort.Slice(occupied, func(i, j int) bool {
+		return occupied[i] < occupied[j]
+	})
+
+	return occupied
+}
+
+// sortedNames returns the names of the given map in ascending order.
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
 func parseConfig(data []byte) (*rawConfig, error) {
 	var config rawConfig
 	err := json.Unmarshal(data, &config)
@@ -5,8 +5,13 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
 	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/bauersimon/ScreenToArtNet/fixture"
+	"github.com/bauersimon/ScreenToArtNet/smoothing"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestConstructUniverses(t *testing.T) {
@@ -40,17 +45,17 @@ func TestConstructUniverses(t *testing.T) {
 					SubNet: 0,
 				},
 			},
-			Devices: map[string]*dmx.Device{
-				"device1": &dmx.Device{
+			Devices: map[string]*rawDevice{
+				"device1": &rawDevice{Device: dmx.Device{
 					R: 1,
 					G: 2,
 					B: 3,
-				},
-				"device2": &dmx.Device{
-					R: 3,
-					G: 2,
-					B: 1,
-				},
+				}},
+				"device2": &rawDevice{Device: dmx.Device{
+					R: 4,
+					G: 5,
+					B: 6,
+				}},
 			},
 			UniversesToDevices: map[string][]string{
 				"universe": []string{
@@ -70,9 +75,9 @@ func TestConstructUniverses(t *testing.T) {
 						B: 3,
 					},
 					&dmx.Device{
-						R: 3,
-						G: 2,
-						B: 1,
+						R: 4,
+						G: 5,
+						B: 6,
 					},
 				},
 			},
@@ -104,12 +109,12 @@ func TestConstructUniverses(t *testing.T) {
 					SubNet: 0,
 				},
 			},
-			Devices: map[string]*dmx.Device{
-				"device1": &dmx.Device{
+			Devices: map[string]*rawDevice{
+				"device1": &rawDevice{Device: dmx.Device{
 					R: 1,
 					G: 2,
 					B: 3,
-				},
+				}},
 			},
 			UniversesToDevices: map[string][]string{
 				"universe": []string{
@@ -119,6 +124,63 @@ func TestConstructUniverses(t *testing.T) {
 		},
 		Error: "unknown device: device2",
 	})
+	validate(t, &testCase{
+		Name: "Invalid Universe",
+
+		Data: &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"universe": &dmx.Universe{
+					Net:    0,
+					SubNet: 0,
+				},
+			},
+			Devices: map[string]*rawDevice{
+				"device": &rawDevice{Device: dmx.Device{
+					R: 1,
+					G: 2,
+					B: 512,
+				}},
+			},
+			UniversesToDevices: map[string][]string{
+				"universe": []string{
+					"device",
+				},
+			},
+		},
+		Error: "invalid universe universe: blue channel outside of DMX range (channel=512)",
+	})
+	validate(t, &testCase{
+		Name: "Overlapping Devices",
+
+		Data: &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"universe": &dmx.Universe{},
+			},
+			Devices: map[string]*rawDevice{
+				"device1": &rawDevice{Device: dmx.Device{
+					R: 1,
+					G: 2,
+					B: 3,
+				}},
+				"device2": &rawDevice{Device: dmx.Device{
+					R: 4,
+					G: 5,
+					B: 6,
+
+					Statics: map[uint16]uint8{
+						3: 255,
+					},
+				}},
+			},
+			UniversesToDevices: map[string][]string{
+				"universe": []string{
+					"device1",
+					"device2",
+				},
+			},
+		},
+		Error: "devices device1 and device2 overlap in universe universe (channel=3)",
+	})
 }
 
 func TestConstructMapping(t *testing.T) {
@@ -170,17 +232,17 @@ func TestConstructMapping(t *testing.T) {
 					},
 				},
 			},
-			Devices: map[string]*dmx.Device{
-				"device1": &dmx.Device{
+			Devices: map[string]*rawDevice{
+				"device1": &rawDevice{Device: dmx.Device{
 					R: 1,
 					G: 2,
 					B: 3,
-				},
-				"device2": &dmx.Device{
+				}},
+				"device2": &rawDevice{Device: dmx.Device{
 					R: 3,
 					G: 2,
 					B: 1,
-				},
+				}},
 			},
 			AreasToDevices: map[string][]string{
 				"area": []string{
@@ -251,12 +313,12 @@ func TestConstructMapping(t *testing.T) {
 					},
 				},
 			},
-			Devices: map[string]*dmx.Device{
-				"device1": &dmx.Device{
+			Devices: map[string]*rawDevice{
+				"device1": &rawDevice{Device: dmx.Device{
 					R: 1,
 					G: 2,
 					B: 3,
-				},
+				}},
 			},
 			AreasToDevices: map[string][]string{
 				"area": []string{
@@ -267,3 +329,588 @@ func TestConstructMapping(t *testing.T) {
 		Error: "unknown device: device2",
 	})
 }
+
+func TestConstructFilters(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data     *rawConfig
+		Expected map[string]smoothing.Config
+		Error    string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			filters, err := tc.Data.constructFilters()
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+
+				assert.Equal(t, len(tc.Expected), len(filters))
+				for areaName, expected := range tc.Expected {
+					f, ok := filters[tc.Data.Areas[areaName]]
+					if assert.True(t, ok, areaName) {
+						assert.Equal(t, expected, f.Config)
+					}
+				}
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"area": &image.Rectangle{
+					Max: image.Point{
+						X: 800,
+						Y: 600,
+					},
+				},
+			},
+			Smoothing: map[string]*smoothing.Config{
+				"area": &smoothing.Config{
+					TimeConstant: 100,
+					SceneCut:     128,
+				},
+			},
+		},
+		Expected: map[string]smoothing.Config{
+			"area": smoothing.Config{
+				TimeConstant: 100,
+				SceneCut:     128,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Area",
+
+		Data: &rawConfig{
+			Smoothing: map[string]*smoothing.Config{
+				"area": &smoothing.Config{},
+			},
+		},
+		Error: "unknown area: area",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Configuration",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"area": &image.Rectangle{},
+			},
+			Smoothing: map[string]*smoothing.Config{
+				"area": &smoothing.Config{
+					MaxSpeed: -1,
+				},
+			},
+		},
+		Error: "invalid maximum speed for smoothing (-1)",
+	})
+	validate(t, &testCase{
+		Name: "Default",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"edge": &image.Rectangle{
+					Max: image.Point{
+						X: 10,
+						Y: 600,
+					},
+				},
+				"center": &image.Rectangle{
+					Max: image.Point{
+						X: 800,
+						Y: 600,
+					},
+				},
+			},
+			Smoothing: map[string]*smoothing.Config{
+				"edge": &smoothing.Config{
+					TimeConstant: 50,
+				},
+			},
+			DefaultSmoothing: &smoothing.Config{
+				TimeConstant: 500,
+			},
+		},
+		Expected: map[string]smoothing.Config{
+			"edge": smoothing.Config{
+				TimeConstant: 50,
+			},
+			"center": smoothing.Config{
+				TimeConstant: 500,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Invalid Default",
+
+		Data: &rawConfig{
+			DefaultSmoothing: &smoothing.Config{
+				MaxSpeed: -1,
+			},
+		},
+		Error: "invalid maximum speed for smoothing (-1)",
+	})
+}
+
+func TestConstructCapture(t *testing.T) {
+	threshold := 20
+	invalidThreshold := 256
+	invalidMonitor := capture.Monitor(-2)
+
+	type testCase struct {
+		Name string
+
+		Data     *rawConfig
+		Expected map[string]capture.AreaConfig
+		Error    string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			configs, err := tc.Data.constructCapture()
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+
+				assert.Equal(t, len(tc.Expected), len(configs))
+				for areaName, expected := range tc.Expected {
+					assert.Equal(t, expected, configs[tc.Data.Areas[areaName]], areaName)
+				}
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"edge":   &image.Rectangle{Max: image.Point{X: 10, Y: 600}},
+				"center": &image.Rectangle{Max: image.Point{X: 800, Y: 600}},
+			},
+			Capture: map[string]*capture.AreaConfig{
+				"edge": &capture.AreaConfig{
+					Spacing: 1,
+				},
+				"center": &capture.AreaConfig{
+					Spacing:   8,
+					Threshold: &threshold,
+					Reducer:   capture.ReducerDominant,
+				},
+			},
+		},
+		Expected: map[string]capture.AreaConfig{
+			"edge": capture.AreaConfig{
+				Spacing: 1,
+			},
+			"center": capture.AreaConfig{
+				Spacing:   8,
+				Threshold: &threshold,
+				Reducer:   capture.ReducerDominant,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Area",
+
+		Data: &rawConfig{
+			Capture: map[string]*capture.AreaConfig{
+				"area": &capture.AreaConfig{},
+			},
+		},
+		Error: "unknown area: area",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Threshold",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"area": &image.Rectangle{},
+			},
+			Capture: map[string]*capture.AreaConfig{
+				"area": &capture.AreaConfig{
+					Threshold: &invalidThreshold,
+				},
+			},
+		},
+		Error: "invalid capture of area area: invalid threshold (threshold=256)",
+	})
+	validate(t, &testCase{
+		Name: "Unknown Reducer",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"area": &image.Rectangle{},
+			},
+			Capture: map[string]*capture.AreaConfig{
+				"area": &capture.AreaConfig{
+					Reducer: "mode",
+				},
+			},
+		},
+		Error: "invalid capture of area area: unknown reducer: mode",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Monitor",
+
+		Data: &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"area": &image.Rectangle{},
+			},
+			Capture: map[string]*capture.AreaConfig{
+				"area": &capture.AreaConfig{
+					Monitor: &invalidMonitor,
+				},
+			},
+		},
+		Error: "invalid capture of area area: invalid monitor (monitor=-2)",
+	})
+}
+
+func TestConstructCorrections(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data     *rawConfig
+		Expected map[string]correction.Chain
+		Error    string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			corrections, err := tc.Data.constructCorrections()
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+
+				assert.Equal(t, len(tc.Expected), len(corrections))
+				for deviceName, expected := range tc.Expected {
+					assert.Equal(t, expected, corrections[&tc.Data.Devices[deviceName].Device], deviceName)
+				}
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Data: &rawConfig{
+			Devices: map[string]*rawDevice{
+				"device": &rawDevice{Device: dmx.Device{
+					R: 1,
+					G: 2,
+					B: 3,
+				}},
+			},
+			Corrections: map[string]*correction.Config{
+				"device": &correction.Config{
+					Saturation: 1.5,
+					Gamma:      2.2,
+				},
+			},
+		},
+		Expected: map[string]correction.Chain{
+			"device": correction.Chain{
+				correction.Saturation(1.5),
+				correction.Gamma(2.2),
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Device",
+
+		Data: &rawConfig{
+			Corrections: map[string]*correction.Config{
+				"device": &correction.Config{},
+			},
+		},
+		Error: "unknown device: device",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Configuration",
+
+		Data: &rawConfig{
+			Devices: map[string]*rawDevice{
+				"device": &rawDevice{Device: dmx.Device{
+					R: 1,
+					G: 2,
+					B: 3,
+				}},
+			},
+			Corrections: map[string]*correction.Config{
+				"device": &correction.Config{
+					Gamma: -1,
+				},
+			},
+		},
+		Error: "invalid gamma (-1)",
+	})
+}
+
+func TestExpandProfiles(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data     *rawConfig
+		Expected map[string]dmx.Device
+		Error    string
+	}
+
+	profiles := map[string]*fixture.Profile{
+		"dimmer-rgb": &fixture.Profile{
+			Name:     "dimmer-rgb",
+			Channels: 4,
+			Roles: map[string]uint16{
+				"Red":   1,
+				"Green": 2,
+				"Blue":  3,
+			},
+			Statics: map[uint16]uint8{
+				0: 255,
+			},
+		},
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Data.expandProfiles(profiles)
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+
+				for deviceName, expected := range tc.Expected {
+					assert.Equal(t, expected, tc.Data.Devices[deviceName].Device, deviceName)
+				}
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Data: &rawConfig{
+			Devices: map[string]*rawDevice{
+				"explicit": &rawDevice{Device: dmx.Device{
+					R: 1,
+					G: 2,
+					B: 3,
+				}},
+				"profile": &rawDevice{
+					Profile: "dimmer-rgb",
+					Address: 16,
+				},
+				"override": &rawDevice{
+					Device: dmx.Device{
+						Statics: map[uint16]uint8{
+							20: 128,
+						},
+						Drivers: map[uint16]*dmx.Driver{
+							24: &dmx.Driver{Source: dmx.SourceLuma},
+						},
+					},
+					Profile: "dimmer-rgb",
+					Address: 20,
+				},
+			},
+		},
+		Expected: map[string]dmx.Device{
+			"explicit": dmx.Device{
+				R: 1,
+				G: 2,
+				B: 3,
+			},
+			"profile": dmx.Device{
+				R: 17,
+				G: 18,
+				B: 19,
+
+				Statics: map[uint16]uint8{
+					16: 255,
+				},
+			},
+			"override": dmx.Device{
+				R: 21,
+				G: 22,
+				B: 23,
+
+				Statics: map[uint16]uint8{
+					20: 128,
+				},
+				Drivers: map[uint16]*dmx.Driver{
+					24: &dmx.Driver{Source: dmx.SourceLuma},
+				},
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Profile",
+
+		Data: &rawConfig{
+			Devices: map[string]*rawDevice{
+				"device": &rawDevice{
+					Profile: "rgb",
+				},
+			},
+		},
+		Error: "unknown profile rgb of device device",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Address",
+
+		Data: &rawConfig{
+			Devices: map[string]*rawDevice{
+				"device": &rawDevice{
+					Profile: "dimmer-rgb",
+					Address: 510,
+				},
+			},
+		},
+		Error: "invalid device device: invalid device of profile dimmer-rgb at address 510: green channel outside of DMX range (channel=512)",
+	})
+}
+
+func TestProfileFootprint(t *testing.T) {
+	profiles := map[string]*fixture.Profile{
+		// The channels 4 to 7 are unused, e.g. strobe and macros.
+		"rgb-8ch": &fixture.Profile{
+			Name:     "rgb-8ch",
+			Channels: 8,
+			Roles: map[string]uint16{
+				"Red":   0,
+				"Green": 1,
+				"Blue":  2,
+			},
+		},
+	}
+
+	type testCase struct {
+		Name string
+
+		Data  string
+		Error string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			raw, err := parseConfig([]byte(tc.Data))
+			require.NoError(t, err)
+
+			err = raw.expandProfiles(profiles)
+			if err == nil {
+				_, err = raw.constructUniverses()
+			}
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Adjacent",
+
+		Data: `{
+			"Universes": {"u1": {}},
+			"Devices": {"a": {"Profile": "rgb-8ch", "Address": 0}, "b": {"Profile": "rgb-8ch", "Address": 8}},
+			"UniversesToDevices": {"u1": ["a", "b"]}
+		}`,
+	})
+	validate(t, &testCase{
+		Name: "Unused Channels Overlap",
+
+		Data: `{
+			"Universes": {"u1": {}},
+			"Devices": {"a": {"Profile": "rgb-8ch", "Address": 0}, "b": {"Profile": "rgb-8ch", "Address": 5}},
+			"UniversesToDevices": {"u1": ["a", "b"]}
+		}`,
+		Error: "devices a and b overlap in universe u1 (channel=5)",
+	})
+	validate(t, &testCase{
+		Name: "Plain Device In Unused Channels",
+
+		Data: `{
+			"Universes": {"u1": {}},
+			"Devices": {"a": {"Profile": "rgb-8ch", "Address": 0}, "par": {"Red": 5, "Green": 6, "Blue": 9}},
+			"UniversesToDevices": {"u1": ["a", "par"]}
+		}`,
+		Error: "devices a and par overlap in universe u1 (channel=5)",
+	})
+	validate(t, &testCase{
+		Name: "Exceeding Footprint",
+
+		Data: `{
+			"Devices": {"a": {"Profile": "rgb-8ch", "Address": 505}}
+		}`,
+		Error: "invalid device a: profile rgb-8ch exceeds the DMX channels (address=505, channels=8)",
+	})
+	validate(t, &testCase{
+		Name: "Profile And Channels",
+
+		Data: `{
+			"Devices": {"a": {"Profile": "rgb-8ch", "Address": 0, "Red": 0}}
+		}`,
+		Error: "device a declares both the profile rgb-8ch and color channels",
+	})
+}
+
+func TestReplaceKey(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data  string
+		Key   string
+		Value string
+
+		Expected    string
+		ExpectedErr string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := replaceKey([]byte(tc.Data), tc.Key, []byte(tc.Value))
+			if tc.ExpectedErr != "" {
+				assert.EqualError(t, err, tc.ExpectedErr)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expected, string(actual))
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Replace",
+
+		Data:  `{"B": 1, "Areas": {"a": 1}, "A": [2]}`,
+		Key:   "Areas",
+		Value: `{"b":2}`,
+
+		Expected: "{\n\t\"B\": 1,\n\t\"Areas\": {\n\t\t\"b\": 2\n\t},\n\t\"A\": [\n\t\t2\n\t]\n}",
+	})
+	validate(t, &testCase{
+		Name: "Append",
+
+		Data:  `{"B": 1}`,
+		Key:   "Areas",
+		Value: `{}`,
+
+		Expected: "{\n\t\"B\": 1,\n\t\"Areas\": {}\n}",
+	})
+	validate(t, &testCase{
+		Name: "Not an Object",
+
+		Data:  `[]`,
+		Key:   "Areas",
+		Value: `{}`,
+
+		ExpectedErr: "configuration is not a JSON object",
+	})
+}
@@ -0,0 +1,143 @@
+package ambilight
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// format identifies the file format of a configuration.
+type format string
+
+const (
+	// formatJSON identifies a JSON configuration.
+	formatJSON format = "json"
+	// formatYAML identifies a YAML configuration.
+	formatYAML format = "yaml"
+	// formatTOML identifies a TOML configuration.
+	formatTOML format = "toml"
+)
+
+// formatOf returns the format of the configuration file by its extension, files with other or no extensions are JSON.
+func formatOf(configPath string) format {
+	switch strings.ToLower(filepath.Ext(configPath)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	default:
+		return formatJSON
+	}
+}
+
+// toJSON converts the configuration of the given format to JSON, so all formats decode into the same structure.
+func toJSON(data []byte, f format) ([]byte, error) {
+	var generic any
+	switch f {
+	case formatJSON:
+		return data, nil
+	case formatYAML:
+		if err := yaml.Unmarshal(data, &generic); err != nil {
+			return nil, err
+		}
+	case formatTOML:
+		if err := toml.Unmarshal(data, &generic); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown configuration format: %s", f)
+	}
+
+	return json.Marshal(normalize(generic))
+}
+
+// normalize converts maps with non-string keys, e.g. YAML channel numbers, into maps with string keys, and JSON numbers into integers or floats.
+func normalize(v any) any {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+
+		return f
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[key] = normalize(value)
+		}
+
+		return m
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = normalize(value)
+		}
+
+		return m
+	case []any:
+		l := make([]any, len(v))
+		for i, value := range v {
+			l[i] = normalize(value)
+		}
+
+		return l
+	case []map[string]any:
+		l := make([]any, len(v))
+		for i, value := range v {
+			l[i] = normalize(value)
+		}
+
+		return l
+	}
+
+	return v
+}
+
+// parseConfigFormat parses the configuration of the given format.
+func parseConfigFormat(data []byte, f format) (*rawConfig, error) {
+	data, err := toJSON(data, f)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse %s configuration: %v", f, err)
+	}
+
+	return parseConfig(data)
+}
+
+// marshalConfig serializes the configuration in the given format, the inverse of parseConfigFormat.
+func marshalConfig(config *rawConfig, f format) ([]byte, error) {
+	data, err := json.MarshalIndent(config, "", "\t")
+	if err != nil {
+		return nil, err
+	}
+	if f == formatJSON {
+		return append(data, '\n'), nil
+	}
+
+	// Keep integers as integers instead of turning them into floats.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic map[string]any
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	switch f {
+	case formatYAML:
+		return yaml.Marshal(normalize(generic))
+	case formatTOML:
+		var buffer bytes.Buffer
+		if err := toml.NewEncoder(&buffer).Encode(normalize(generic)); err != nil {
+			return nil, err
+		}
+
+		return buffer.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unknown configuration format: %s", f)
+	}
+}
@@ -0,0 +1,257 @@
+package ambilight
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+// formatConfigJSON holds a configuration using most features, equal to formatConfigYAML and formatConfigTOML.
+const formatConfigJSON = `{
+	"Areas": {
+		"bar": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 1920, "Y": 1080}}
+	},
+	"Universes": {
+		"u1": {"Net": 0, "SubNet": 1}
+	},
+	"Devices": {
+		"foo": {
+			"Red": 1, "Green": 2, "Blue": 3, "WhiteFine": 4,
+			"Statics": {"7": 255},
+			"Drivers": {"8": {"Source": "luma", "Min": 20}}
+		},
+		"par": {"Profile": "generic-rgbw-8ch", "Address": 17, "Statics": {"0": 200}}
+	},
+	"UniversesToDevices": {"u1": ["foo"]},
+	"AreasToDevices": {"bar": ["foo"]},
+	"Smoothing": {"bar": {"TimeConstant": 100, "SceneCut": 128}},
+	"Corrections": {"foo": {"WhiteBalance": [1, 0.9, 0.8], "Gamma": 2.2}},
+	"Strips": {
+		"top": {"Universe": "u1", "Address": 32, "Pixels": 10, "Order": "GRB", "Line": [{"X": 0, "Y": 0}, {"X": 100, "Y": 0}], "Depth": 10}
+	},
+	"Presets": {
+		"warm": {"Devices": {"foo": {"Color": "#ffb060", "Channels": {"7": 128}}}}
+	}
+}`
+
+const formatConfigYAML = `
+# Comments are allowed.
+Areas:
+  bar: {Min: {X: 0, Y: 0}, Max: {X: 1920, Y: 1080}}
+Universes:
+  u1: {Net: 0, SubNet: 1}
+Devices:
+  foo:
+    Red: 1
+    Green: 2
+    Blue: 3
+    WhiteFine: 4
+    Statics: {7: 255}
+    Drivers:
+      8: {Source: luma, Min: 20}
+  par: {Profile: generic-rgbw-8ch, Address: 17, Statics: {0: 200}}
+UniversesToDevices:
+  u1: [foo]
+AreasToDevices:
+  bar: [foo]
+Smoothing:
+  bar: {TimeConstant: 100, SceneCut: 128}
+Corrections:
+  foo: {WhiteBalance: [1, 0.9, 0.8], Gamma: 2.2}
+Strips:
+  top:
+    Universe: u1
+    Address: 32
+    Pixels: 10
+    Order: GRB
+    Line: [{X: 0, Y: 0}, {X: 100, Y: 0}]
+    Depth: 10
+Presets:
+  warm:
+    Devices:
+      foo: {Color: "#ffb060", Channels: {7: 128}}
+`
+
+const formatConfigTOML = `
+# Comments are allowed.
+[Areas.bar]
+Min = {X = 0, Y = 0}
+Max = {X = 1920, Y = 1080}
+
+[Universes.u1]
+Net = 0
+SubNet = 1
+
+[Devices.foo]
+Red = 1
+Green = 2
+Blue = 3
+WhiteFine = 4
+Statics = {7 = 255}
+Drivers = {8 = {Source = "luma", Min = 20}}
+
+[Devices.par]
+Profile = "generic-rgbw-8ch"
+Address = 17
+Statics = {0 = 200}
+
+[UniversesToDevices]
+u1 = ["foo"]
+
+[AreasToDevices]
+bar = ["foo"]
+
+[Smoothing.bar]
+TimeConstant = 100
+SceneCut = 128
+
+[Corrections.foo]
+WhiteBalance = [1, 0.9, 0.8]
+Gamma = 2.2
+
+[Strips.top]
+Universe = "u1"
+Address = 32
+Pixels = 10
+Order = "GRB"
+Line = [{X = 0, Y = 0}, {X = 100, Y = 0}]
+Depth = 10
+
+[Presets.warm.Devices.foo]
+Color = "#ffb060"
+Channels = {7 = 128}
+`
+
+func TestFormatOf(t *testing.T) {
+	for path, expected := range map[string]format{
+		"config.json":    formatJSON,
+		"config.yaml":    formatYAML,
+		"config.YML":     formatYAML,
+		"/etc/foo.toml":  formatTOML,
+		"config.json.in": formatJSON,
+		"ambilight.conf": formatJSON,
+		"ambilight":      formatJSON,
+	} {
+		assert.Equal(t, expected, formatOf(path), path)
+	}
+}
+
+func TestParseConfigFormat(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data   string
+		Format format
+
+		Error error
+	}
+
+	expected, err := parseConfig([]byte(formatConfigJSON))
+	require.NoError(t, err)
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := parseConfigFormat([]byte(tc.Data), tc.Format)
+			if tc.Error != nil {
+				assert.Equal(t, tc.Error, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, expected, actual)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "JSON",
+
+		Data:   formatConfigJSON,
+		Format: formatJSON,
+	})
+	validate(t, &testCase{
+		Name: "YAML",
+
+		Data:   formatConfigYAML,
+		Format: formatYAML,
+	})
+	validate(t, &testCase{
+		Name: "TOML",
+
+		Data:   formatConfigTOML,
+		Format: formatTOML,
+	})
+	validate(t, &testCase{
+		Name: "Invalid YAML",
+
+		Data:   "Areas: [",
+		Format: formatYAML,
+
+		Error: errors.New("cannot parse yaml configuration: yaml: line 1: did not find expected node content"),
+	})
+}
+
+func TestConfigRoundTrip(t *testing.T) {
+	expected, err := parseConfig([]byte(formatConfigJSON))
+	require.NoError(t, err)
+
+	// Encode the configuration in every format and decode it again, the runtime values are not encoded.
+	expected.Devices["foo"].RValue = 0x1234
+	data, err := json.Marshal(expected)
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "RValue")
+	expected.Devices["foo"].RValue = 0
+	var generic map[string]any
+	require.NoError(t, json.Unmarshal(data, &generic))
+
+	encoders := map[format]func(v any) ([]byte, error){
+		formatJSON: json.Marshal,
+		formatYAML: yaml.Marshal,
+		formatTOML: func(v any) ([]byte, error) {
+			var buffer bytes.Buffer
+			err := toml.NewEncoder(&buffer).Encode(v)
+
+			return buffer.Bytes(), err
+		},
+	}
+	for f, encode := range encoders {
+		t.Run(string(f), func(t *testing.T) {
+			data, err := encode(generic)
+			require.NoError(t, err)
+
+			actual, err := parseConfigFormat(data, f)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+		})
+	}
+}
+
+func TestMarshalConfig(t *testing.T) {
+	expected, err := parseConfig([]byte(formatConfigJSON))
+	require.NoError(t, err)
+
+	// Encode the configuration in every format and decode it again, the runtime values are not encoded.
+	for _, f := range []format{formatJSON, formatYAML, formatTOML} {
+		t.Run(string(f), func(t *testing.T) {
+			expected.Devices["foo"].RValue = 0x1234
+			data, err := marshalConfig(expected, f)
+			require.NoError(t, err)
+			expected.Devices["foo"].RValue = 0
+
+			actual, err := parseConfigFormat(data, f)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, actual)
+			assert.NotContains(t, string(data), "Value")
+		})
+	}
+
+	data, err := marshalConfig(expected, formatTOML)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "Pixels = 10\n")
+	assert.NotContains(t, string(data), "Profiles")
+	assert.NotContains(t, string(data), "Red = 0")
+}
@@ -0,0 +1,180 @@
+package ambilight
+
+import (
+	"fmt"
+	"image"
+	"os"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// Layout holds LED strips running clockwise around a monitor, starting at its top left corner.
+type Layout struct {
+	// Screen holds the size of the monitor.
+	Screen image.Point
+
+	// Top holds the number of LEDs along the top edge.
+	Top int
+	// Right holds the number of LEDs along the right edge.
+	Right int
+	// Bottom holds the number of LEDs along the bottom edge.
+	Bottom int
+	// Left holds the number of LEDs along the left edge.
+	Left int
+
+	// Order holds the channel order of a LED, e.g. "GRB".
+	Order string
+	// Depth holds the size of the sampled screen areas perpendicular to the edges.
+	Depth int
+
+	// Universe holds the universe of the first LED, further universes follow consecutively.
+	Universe dmx.Universe
+	// Address holds the first channel of the first LED.
+	Address uint16
+
+	// Settings holds the run settings of the configuration, e.g. the ArtNet node and monitor.
+	Settings Settings
+}
+
+// edges holds the edges of a layout in the order the strips are connected.
+var edges = []string{"top", "right", "bottom", "left"}
+
+// pixels returns the number of LEDs along the given edge.
+func (l *Layout) pixels(edge string) int {
+	switch edge {
+	case "top":
+		return l.Top
+	case "right":
+		return l.Right
+	case "bottom":
+		return l.Bottom
+	case "left":
+		return l.Left
+	}
+
+	return 0
+}
+
+// verify checks if the layout describes a valid configuration.
+func (l *Layout) verify() error {
+	if l.Screen.X < 1 || l.Screen.Y < 1 {
+		return fmt.Errorf("invalid screen size (screen=%v)", l.Screen)
+	}
+
+	total := 0
+	for _, edge := range edges {
+		if l.pixels(edge) < 0 {
+			return fmt.Errorf("invalid LED count of %s edge (leds=%v)", edge, l.pixels(edge))
+		}
+		total += l.pixels(edge)
+	}
+	if total == 0 {
+		return fmt.Errorf("layout has no LEDs")
+	}
+
+	if l.Depth < 1 {
+		return fmt.Errorf("invalid depth (depth=%v)", l.Depth)
+	}
+	if l.Address > 511 {
+		return fmt.Errorf("invalid address (address=%v)", l.Address)
+	}
+
+	return l.Universe.Verify()
+}
+
+// config returns the configuration of the layout with one strip per edge.
+func (l *Layout) config() (*rawConfig, error) {
+	if err := l.verify(); err != nil {
+		return nil, err
+	}
+
+	config := &rawConfig{
+		Areas: map[string]*image.Rectangle{
+			"screen": &image.Rectangle{Max: l.Screen},
+		},
+		Universes: map[string]*dmx.Universe{},
+		Strips:    map[string]*rawStrip{},
+	}
+	if l.Settings != (Settings{}) {
+		settings := l.Settings
+		config.Settings = &settings
+	}
+
+	universeName, universe := "u1", &dmx.Universe{Net: l.Universe.Net, SubNet: l.Universe.SubNet}
+	config.Universes[universeName] = universe
+
+	width := uint16(len(l.Order))
+	address := l.Address
+	for _, edge := range edges {
+		pixels := l.pixels(edge)
+		if pixels == 0 {
+			continue
+		}
+
+		strip := &rawStrip{
+			Pixels: pixels,
+			Order:  l.Order,
+			Area:   "screen",
+			Edge:   edge,
+			Depth:  l.Depth,
+			// The strip runs clockwise, so it runs backwards along the bottom and left edges.
+			Reverse: edge == "bottom" || edge == "left",
+		}
+		if err := strip.verify(); err != nil {
+			return nil, fmt.Errorf("invalid %s edge: %v", edge, err)
+		}
+
+		// Follow the strip through the universes the same way its expansion rolls over.
+		for i := 0; i < pixels; i++ {
+			if address+width > 512 {
+				next := nextPortAddress(universe)
+				if err := next.Verify(); err != nil {
+					return nil, fmt.Errorf("layout exceeds the ArtNet universes: %v", err)
+				}
+				universeName, universe = fmt.Sprintf("u%d", len(config.Universes)+1), next
+				config.Universes[universeName] = universe
+				address = 0
+			}
+			if i == 0 {
+				strip.Universe = universeName
+				strip.Address = address
+			}
+
+			address += width
+		}
+
+		config.Strips[edge] = strip
+	}
+
+	return config, nil
+}
+
+// WriteLayout writes the complete configuration of the layout to the given file in the format of its extension.
+// Existing files are not overwritten.
+func WriteLayout(configPath string, layout *Layout) error {
+	config, err := layout.config()
+	if err != nil {
+		return err
+	}
+
+	data, err := marshalConfig(config, formatOf(configPath))
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		if os.IsExist(err) {
+			return fmt.Errorf("configuration %s already exists", configPath)
+		}
+
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+
+		return err
+	}
+
+	return file.Close()
+}
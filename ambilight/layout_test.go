@@ -0,0 +1,204 @@
+package ambilight
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLayoutConfig(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Layout Layout
+
+		ExpectedUniverses map[string]*dmx.Universe
+		ExpectedStrips    map[string]*rawStrip
+		Error             string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := tc.Layout.config()
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, map[string]*image.Rectangle{
+				"screen": &image.Rectangle{Max: tc.Layout.Screen},
+			}, actual.Areas)
+			assert.Equal(t, tc.ExpectedUniverses, actual.Universes)
+			assert.Equal(t, tc.ExpectedStrips, actual.Strips)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Single Universe",
+
+		Layout: Layout{
+			Screen: image.Point{X: 1920, Y: 1080},
+			Top:    30,
+			Left:   20,
+			Order:  "GRB",
+			Depth:  100,
+
+			Universe: dmx.Universe{Net: 1, SubNet: 2},
+			Address:  10,
+		},
+
+		ExpectedUniverses: map[string]*dmx.Universe{
+			"u1": &dmx.Universe{Net: 1, SubNet: 2},
+		},
+		ExpectedStrips: map[string]*rawStrip{
+			"top": &rawStrip{
+				Universe: "u1",
+				Address:  10,
+				Pixels:   30,
+				Order:    "GRB",
+				Area:     "screen",
+				Edge:     "top",
+				Depth:    100,
+			},
+			"left": &rawStrip{
+				Universe: "u1",
+				Address:  100,
+				Pixels:   20,
+				Order:    "GRB",
+				Area:     "screen",
+				Edge:     "left",
+				Depth:    100,
+				Reverse:  true,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Rolling Over",
+
+		Layout: Layout{
+			Screen: image.Point{X: 1920, Y: 1080},
+			Top:    100,
+			Right:  100,
+			Bottom: 100,
+			Left:   100,
+			Order:  "RGB",
+			Depth:  50,
+
+			Universe: dmx.Universe{SubNet: 15},
+		},
+
+		ExpectedUniverses: map[string]*dmx.Universe{
+			"u1": &dmx.Universe{SubNet: 15},
+			"u2": &dmx.Universe{Net: 1},
+			"u3": &dmx.Universe{Net: 1, SubNet: 1},
+		},
+		ExpectedStrips: map[string]*rawStrip{
+			"top": &rawStrip{
+				Universe: "u1",
+				Pixels:   100,
+				Order:    "RGB",
+				Area:     "screen",
+				Edge:     "top",
+				Depth:    50,
+			},
+			"right": &rawStrip{
+				Universe: "u1",
+				Address:  300,
+				Pixels:   100,
+				Order:    "RGB",
+				Area:     "screen",
+				Edge:     "right",
+				Depth:    50,
+			},
+			"bottom": &rawStrip{
+				Universe: "u2",
+				Address:  90,
+				Pixels:   100,
+				Order:    "RGB",
+				Area:     "screen",
+				Edge:     "bottom",
+				Depth:    50,
+				Reverse:  true,
+			},
+			"left": &rawStrip{
+				Universe: "u2",
+				Address:  390,
+				Pixels:   100,
+				Order:    "RGB",
+				Area:     "screen",
+				Edge:     "left",
+				Depth:    50,
+				Reverse:  true,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "No LEDs",
+
+		Layout: Layout{
+			Screen: image.Point{X: 1920, Y: 1080},
+			Order:  "RGB",
+			Depth:  50,
+		},
+
+		Error: "layout has no LEDs",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Order",
+
+		Layout: Layout{
+			Screen: image.Point{X: 1920, Y: 1080},
+			Top:    10,
+			Order:  "RGX",
+			Depth:  50,
+		},
+
+		Error: "invalid top edge: invalid pixel order: RGX",
+	})
+	validate(t, &testCase{
+		Name: "Exceeding Universes",
+
+		Layout: Layout{
+			Screen: image.Point{X: 1920, Y: 1080},
+			Top:    200,
+			Order:  "RGB",
+			Depth:  50,
+
+			Universe: dmx.Universe{Net: 127, SubNet: 15},
+		},
+
+		Error: "layout exceeds the ArtNet universes: invalid ArtNet net (net=128)",
+	})
+}
+
+func TestWriteLayout(t *testing.T) {
+	layout := &Layout{
+		Screen: image.Point{X: 1920, Y: 1080},
+		Top:    60,
+		Right:  30,
+		Bottom: 60,
+		Left:   30,
+		Order:  "GRB",
+		Depth:  100,
+	}
+
+	for _, name := range []string{"config.json", "config.yaml", "config.toml"} {
+		t.Run(name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), name)
+			require.NoError(t, WriteLayout(configPath, layout))
+
+			config, err := ReadConfig(configPath)
+			require.NoError(t, err)
+			assert.Len(t, config.Universes, 2)
+			assert.Len(t, config.DeviceNames, 180)
+
+			assert.EqualError(t, WriteLayout(configPath, layout), "configuration "+configPath+" already exists")
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package ambilight
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+)
+
+// configDirectory holds the name of the directory searched in the XDG configuration directories.
+const configDirectory = "screentoartnet"
+
+// resolveConfigPath returns the absolute path of the configuration file.
+// Relative paths are searched in the working directory, the XDG configuration home and the XDG configuration directories, in that order.
+// If no file exists, the path in the working directory is returned.
+func resolveConfigPath(configPath string) (string, error) {
+	if filepath.IsAbs(configPath) {
+		return filepath.Clean(configPath), nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	candidates := []string{filepath.Join(cwd, configPath)}
+	for _, dir := range configDirs() {
+		candidates = append(candidates, filepath.Join(dir, configDirectory, configPath))
+	}
+
+	for _, candidate := range candidates {
+		if _, err := os.Stat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+
+	return candidates[0], nil
+}
+
+// configDirs returns the XDG configuration home followed by the XDG configuration directories.
+func configDirs() []string {
+	var dirs []string
+
+	if home := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(home) {
+		dirs = append(dirs, home)
+	} else if home, err := os.UserHomeDir(); err == nil {
+		dirs = append(dirs, filepath.Join(home, ".config"))
+	}
+
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
+	}
+	for _, dir := range filepath.SplitList(configDirs) {
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+// origins holds the file declaring each entry per configuration section, e.g. origins["Devices"]["foo"].
+type origins map[string]map[string]string
+
+// loadConfig reads the configuration file at the absolute path, merged with all the files it includes.
+func loadConfig(configPath string) (*rawConfig, origins, error) {
+	merged := &rawConfig{}
+	declared := origins{}
+	if err := merged.include(configPath, declared, map[string]bool{}); err != nil {
+		return nil, nil, err
+	}
+
+	return merged, declared, nil
+}
+
+// readConfigFile reads a single configuration file at the absolute path, without its includes.
+// The profile directory is made absolute.
+func readConfigFile(configPath string) (*rawConfig, error) {
+	data, err := ioutil.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := parseConfigFormat(data, formatOf(configPath))
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration %s: %v", configPath, err)
+	}
+
+	if raw.Profiles != "" && !filepath.IsAbs(raw.Profiles) {
+		raw.Profiles = filepath.Join(filepath.Dir(configPath), raw.Profiles)
+	}
+
+	return raw, nil
+}
+
+// include merges the configuration file at the absolute path and its includes, which are relative to the including file.
+// Files that were loaded already are skipped.
+func (r *rawConfig) include(configPath string, declared origins, loaded map[string]bool) error {
+	if loaded[configPath] {
+		return nil
+	}
+	loaded[configPath] = true
+
+	raw, err := readConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+
+	if err := r.merge(raw, configPath, declared); err != nil {
+		return err
+	}
+
+	for _, include := range raw.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(configPath), include)
+		}
+		if err := r.include(filepath.Clean(include), declared, loaded); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// merge adds the entries of every section and the settings of the other configuration, which must not be declared already.
+func (r *rawConfig) merge(other *rawConfig, otherPath string, declared origins) error {
+	if other.Profiles != "" {
+		if r.Profiles != "" && r.Profiles != other.Profiles {
+			return fmt.Errorf("conflicting Profiles in %s and %s", declared["Profiles"][""], otherPath)
+		}
+		r.Profiles = other.Profiles
+		declared["Profiles"] = map[string]string{"": otherPath}
+	}
+	if other.DefaultSmoothing != nil {
+		if r.DefaultSmoothing != nil {
+			return fmt.Errorf("conflicting DefaultSmoothing in %s and %s", declared["DefaultSmoothing"][""], otherPath)
+		}
+		r.DefaultSmoothing = other.DefaultSmoothing
+		declared["DefaultSmoothing"] = map[string]string{"": otherPath}
+	}
+	if other.Window != nil {
+		if r.Window != nil {
+			return fmt.Errorf("conflicting Window in %s and %s", declared["Window"][""], otherPath)
+		}
+		r.Window = other.Window
+		declared["Window"] = map[string]string{"": otherPath}
+	}
+
+	if err := r.mergeSettings(other, otherPath, declared); err != nil {
+		return err
+	}
+
+	target := reflect.ValueOf(r).Elem()
+	source := reflect.ValueOf(other).Elem()
+	for i := 0; i < target.NumField(); i++ {
+		if target.Field(i).Kind() != reflect.Map {
+			continue
+		}
+		section := target.Type().Field(i).Name
+
+		entries := source.Field(i)
+		if entries.Len() == 0 {
+			continue
+		}
+		if target.Field(i).IsNil() {
+			target.Field(i).Set(reflect.MakeMap(entries.Type()))
+		}
+		if declared[section] == nil {
+			declared[section] = map[string]string{}
+		}
+
+		keys := entries.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, key := range keys {
+			name := key.String()
+			if file, ok := declared[section][name]; ok {
+				return fmt.Errorf("conflicting %s entry %s in %s and %s", section, name, file, otherPath)
+			}
+			declared[section][name] = otherPath
+
+			target.Field(i).SetMapIndex(key, entries.MapIndex(key))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,355 @@
+package ambilight
+
+import (
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeFiles writes the files with their content relative to the directory.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+}
+
+func TestResolveConfigPath(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Files      []string
+		ConfigPath string
+
+		Expected string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			root := t.TempDir()
+			for _, f := range tc.Files {
+				writeFiles(t, root, map[string]string{f: "{}"})
+			}
+
+			cwd, err := os.Getwd()
+			require.NoError(t, err)
+			require.NoError(t, os.Chdir(filepath.Join(root, "cwd")))
+			defer os.Chdir(cwd)
+			t.Setenv("XDG_CONFIG_HOME", filepath.Join(root, "home"))
+			t.Setenv("XDG_CONFIG_DIRS", filepath.Join(root, "etc1")+string(filepath.ListSeparator)+filepath.Join(root, "etc2"))
+
+			actual, err := resolveConfigPath(tc.ConfigPath)
+			assert.NoError(t, err)
+			// The temporary directory might be behind a symbolic link.
+			actual, err = filepath.Rel(root, actual)
+			if err != nil || actual[0] == '.' {
+				actual, _ = filepath.EvalSymlinks(filepath.Join(root, actual))
+				realRoot, _ := filepath.EvalSymlinks(root)
+				actual, _ = filepath.Rel(realRoot, actual)
+			}
+			assert.Equal(t, tc.Expected, actual)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Working Directory",
+
+		Files:      []string{"cwd/config.json", "home/screentoartnet/config.json"},
+		ConfigPath: "config.json",
+
+		Expected: "cwd/config.json",
+	})
+	validate(t, &testCase{
+		Name: "Configuration Home",
+
+		Files:      []string{"cwd/other.json", "home/screentoartnet/config.json", "etc1/screentoartnet/config.json"},
+		ConfigPath: "config.json",
+
+		Expected: "home/screentoartnet/config.json",
+	})
+	validate(t, &testCase{
+		Name: "Configuration Directories",
+
+		Files:      []string{"cwd/other.json", "etc2/screentoartnet/config.json"},
+		ConfigPath: "config.json",
+
+		Expected: "etc2/screentoartnet/config.json",
+	})
+	validate(t, &testCase{
+		Name: "Missing",
+
+		Files:      []string{"cwd/other.json"},
+		ConfigPath: "config.json",
+
+		Expected: "cwd/config.json",
+	})
+}
+
+func TestResolveConfigPathAbsolute(t *testing.T) {
+	actual, err := resolveConfigPath("/etc/screentoartnet/../screentoartnet/config.json")
+	assert.NoError(t, err)
+	assert.Equal(t, "/etc/screentoartnet/config.json", actual)
+}
+
+func TestLoadConfig(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Files map[string]string
+
+		Expected func(dir string) *rawConfig
+		Error    func(dir string) string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			writeFiles(t, dir, tc.Files)
+
+			actual, _, err := loadConfig(filepath.Join(dir, "config.json"))
+			if tc.Error != nil {
+				assert.EqualError(t, err, tc.Error(dir))
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expected(dir), actual)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Merged",
+
+		Files: map[string]string{
+			"config.json": `{
+				"Include": ["shared/fixtures.yaml"],
+				"Areas": {"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 10, "Y": 10}}},
+				"AreasToDevices": {"left": ["par"]}
+			}`,
+			"shared/fixtures.yaml": `
+Include: [../config.json]
+Profiles: profiles
+Devices:
+  par: {Profile: generic, Address: 1}
+`,
+		},
+		Expected: func(dir string) *rawConfig {
+			return &rawConfig{
+				Areas: map[string]*image.Rectangle{
+					"left": &image.Rectangle{Max: image.Point{X: 10, Y: 10}},
+				},
+				Devices: map[string]*rawDevice{
+					"par": &rawDevice{Profile: "generic", Address: 1},
+				},
+				Profiles: filepath.Join(dir, "shared", "profiles"),
+				AreasToDevices: map[string][]string{
+					"left": []string{"par"},
+				},
+			}
+		},
+	})
+	validate(t, &testCase{
+		Name: "Conflict",
+
+		Files: map[string]string{
+			"config.json": `{"Include": ["room.json"], "Devices": {"par": {"Red": 1, "Green": 2, "Blue": 3}}}`,
+			"room.json":   `{"Devices": {"par": {"Red": 4, "Green": 5, "Blue": 6}}}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("conflicting Devices entry par in %s and %s", filepath.Join(dir, "config.json"), filepath.Join(dir, "room.json"))
+		},
+	})
+	validate(t, &testCase{
+		Name: "Conflicting Profiles",
+
+		Files: map[string]string{
+			"config.json":     `{"Include": ["rooms/room.json"], "Profiles": "profiles"}`,
+			"rooms/room.json": `{"Profiles": "profiles"}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("conflicting Profiles in %s and %s", filepath.Join(dir, "config.json"), filepath.Join(dir, "rooms", "room.json"))
+		},
+	})
+	validate(t, &testCase{
+		Name: "Conflicting DefaultSmoothing",
+
+		Files: map[string]string{
+			"config.json": `{"Include": ["room.json"], "DefaultSmoothing": {"TimeConstant": 100}}`,
+			"room.json":   `{"DefaultSmoothing": {"TimeConstant": 100}}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("conflicting DefaultSmoothing in %s and %s", filepath.Join(dir, "config.json"), filepath.Join(dir, "room.json"))
+		},
+	})
+	validate(t, &testCase{
+		Name: "Conflicting Window",
+
+		Files: map[string]string{
+			"config.json": `{"Include": ["room.json"], "Window": {"Class": "vlc"}}`,
+			"room.json":   `{"Window": {"Class": "mpv"}}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("conflicting Window in %s and %s", filepath.Join(dir, "config.json"), filepath.Join(dir, "room.json"))
+		},
+	})
+	validate(t, &testCase{
+		Name: "Conflicting Settings",
+
+		Files: map[string]string{
+			"config.json": `{"Include": ["room.json"], "Settings": {"Dst": "2.0.0.1", "Pause": 10}}`,
+			"room.json":   `{"Settings": {"Dst": "2.0.0.2"}}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("conflicting Settings entry Dst in %s and %s", filepath.Join(dir, "config.json"), filepath.Join(dir, "room.json"))
+		},
+	})
+	validate(t, &testCase{
+		Name: "Missing Include",
+
+		Files: map[string]string{
+			"config.json": `{"Include": ["room.json"]}`,
+		},
+		Error: func(dir string) string {
+			return fmt.Sprintf("open %s: no such file or directory", filepath.Join(dir, "room.json"))
+		},
+	})
+}
+
+func TestWriteAreasIncluded(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json": `{"Include": ["layout.json"], "Areas": {"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 10, "Y": 10}}}}`,
+		"layout.json": `{"Areas": {"right": {"Min": {"X": 10, "Y": 0}, "Max": {"X": 20, "Y": 10}}}}`,
+	})
+	configPath := filepath.Join(dir, "config.json")
+
+	require.NoError(t, WriteAreas(configPath, map[string]image.Rectangle{
+		"right":  image.Rect(15, 0, 20, 10),
+		"center": image.Rect(5, 5, 15, 10),
+	}))
+
+	layout, err := readConfigFile(filepath.Join(dir, "layout.json"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*image.Rectangle{
+		"right": &image.Rectangle{Min: image.Point{X: 15}, Max: image.Point{X: 20, Y: 10}},
+	}, layout.Areas)
+
+	areas, err := ReadAreas(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]image.Rectangle{
+		"left":   image.Rect(0, 0, 10, 10),
+		"right":  image.Rect(15, 0, 20, 10),
+		"center": image.Rect(5, 5, 15, 10),
+	}, areas)
+}
+
+func TestReadConfigOrder(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.json": `{
+			"Areas": {
+				"top": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 30, "Y": 10}},
+				"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 10, "Y": 30}},
+				"right": {"Min": {"X": 20, "Y": 0}, "Max": {"X": 30, "Y": 30}}
+			},
+			"Universes": {"upstairs": {"Net": 0, "SubNet": 1}, "downstairs": {"Net": 0, "SubNet": 0}},
+			"Devices": {"par": {"Red": 0, "Green": 1, "Blue": 2}, "bar": {"Red": 0, "Green": 1, "Blue": 2}},
+			"UniversesToDevices": {"upstairs": ["par"], "downstairs": ["bar"]}
+		}`,
+	})
+
+	config, err := ReadConfig(filepath.Join(dir, "config.json"))
+	require.NoError(t, err)
+
+	var areas []string
+	for _, a := range config.Areas {
+		areas = append(areas, config.AreaNames[a])
+	}
+	assert.Equal(t, []string{"left", "right", "top"}, areas)
+	var universes []string
+	for _, u := range config.Universes {
+		universes = append(universes, config.UniverseNames[u])
+	}
+	assert.Equal(t, []string{"downstairs", "upstairs"}, universes)
+
+	left, ok := config.Area("left")
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(0, 0, 10, 30), *left)
+	_, ok = config.Area("bottom")
+	assert.False(t, ok)
+
+	upstairs, ok := config.Universe("upstairs")
+	assert.True(t, ok)
+	assert.Equal(t, uint8(1), upstairs.SubNet)
+
+	par, ok := config.Device("par")
+	assert.True(t, ok)
+	assert.Equal(t, []*dmx.Device{par}, upstairs.Devices)
+	_, ok = config.Device("spot")
+	assert.False(t, ok)
+}
+
+func TestReadSettings(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml":  "Include: [network.yaml]\nSettings: {Pause: 0, Screen: 1}\n",
+		"network.yaml": "Settings: {Dst: 2.0.0.1}\n",
+	})
+
+	settings, err := ReadSettings(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+
+	dst, pause, screen := "2.0.0.1", 0, 1
+	assert.Equal(t, &Settings{Dst: &dst, Pause: &pause, Screen: &screen}, settings)
+}
+
+func TestReadCapture(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"config.yaml": "" +
+			"Areas:\n" +
+			"  game: {Min: {X: 0, Y: 0}, Max: {X: 1280, Y: 100}}\n" +
+			"  desk: {Min: {X: 1820, Y: 0}, Max: {X: 2020, Y: 100}}\n" +
+			"Capture:\n" +
+			"  desk: {Monitor: all, Reducer: median}\n" +
+			"Window: {Class: vlc}\n" +
+			"DefaultSmoothing: {TimeConstant: 200}\n",
+		"invalid.yaml": "Window: {}\n",
+	})
+
+	config, err := ReadConfig(filepath.Join(dir, "config.yaml"))
+	require.NoError(t, err)
+
+	assert.Equal(t, &capture.WindowTarget{Class: "vlc"}, config.Window)
+	desk, _ := config.Area("desk")
+	all := capture.MonitorAll
+	assert.Equal(t, capture.AreaConfig{Monitor: &all, Reducer: capture.ReducerMedian}, config.Capture[desk])
+	game, _ := config.Area("game")
+	if assert.Contains(t, config.Filters, game) {
+		assert.Equal(t, 200, config.Filters[game].Config.TimeConstant)
+	}
+
+	_, err = ReadConfig(filepath.Join(dir, "invalid.yaml"))
+	assert.EqualError(t, err, "invalid window: window needs a title, class or process")
+}
+
+func TestReadConfigWithoutExtension(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"ambilight.conf": `{"Areas": {"top": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 30, "Y": 10}}}}`,
+	})
+
+	config, err := ReadConfig(filepath.Join(dir, "ambilight.conf"))
+	require.NoError(t, err)
+
+	_, ok := config.Area("top")
+	assert.True(t, ok)
+}
@@ -0,0 +1,182 @@
+package ambilight
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/correction"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// ModeAmbilight is the mode following the screen, all other modes play back a preset.
+const ModeAmbilight = "ambilight"
+
+// Preset holds a static look of the DMX devices.
+type Preset struct {
+	// Colors holds the colors of the devices, devices without a color are black.
+	Colors map[*dmx.Device]color.RGBA64
+	// Channels holds raw channel values per device, taking precedence over the color and statics of the device.
+	Channels map[*dmx.Device]map[uint16]uint8
+}
+
+// frame returns the DMX frame of the universe showing the preset, scaled by the master intensity between 0 and 1.
+func (p *Preset) frame(u *dmx.Universe, corrections Corrections, master float64) dmx.DMXFrame {
+	var frame dmx.DMXFrame
+
+	for _, d := range u.Devices {
+		// Work on a copy so the live values of the device stay untouched.
+		pd := *d
+
+		c := p.Colors[d]
+		if chain, ok := corrections[d]; ok && c != (color.RGBA64{}) {
+			c = chain.Apply(correction.FromRGBA64(c)).RGBA64()
+		}
+		pd.SetColor(c.R, c.G, c.B)
+
+		if channels, ok := p.Channels[d]; ok {
+			pd.Statics = map[uint16]uint8{}
+			for channel, value := range d.Statics {
+				pd.Statics[channel] = value
+			}
+			for channel, value := range channels {
+				pd.Statics[channel] = value
+			}
+
+			pd.Drivers = map[uint16]*dmx.Driver{}
+			for channel, driver := range d.Drivers {
+				if _, ok := channels[channel]; !ok {
+					pd.Drivers[channel] = driver
+				}
+			}
+		}
+
+		pd.UpdateFrame(&frame, master)
+	}
+
+	return frame
+}
+
+// rawPreset holds a static look of the DMX devices.
+type rawPreset struct {
+	// Devices holds device names and their respective look, devices not listed are black.
+	Devices map[string]*rawPresetDevice
+}
+
+// rawPresetDevice holds the look of a DMX device in a preset.
+type rawPresetDevice struct {
+	// Color holds the 8-bit hex color of the device, e.g. "#ffb060".
+	Color string `json:",omitempty"`
+	// Channels holds raw channel values of the device, taking precedence over the color and statics.
+	Channels map[uint16]uint8 `json:",omitempty"`
+}
+
+func (r *rawConfig) constructPresets() (presets map[string]*Preset, err error) {
+	presets = map[string]*Preset{}
+	for _, presetName := range sortedNames(r.Presets) {
+		rp := r.Presets[presetName]
+		if presetName == ModeAmbilight {
+			return nil, fmt.Errorf("reserved preset name: %s", presetName)
+		}
+
+		p := &Preset{
+			Colors:   map[*dmx.Device]color.RGBA64{},
+			Channels: map[*dmx.Device]map[uint16]uint8{},
+		}
+		for deviceName, rd := range rp.Devices {
+			d, ok := r.Devices[deviceName]
+			if !ok {
+				return nil, fmt.Errorf("unknown device %s in preset %s", deviceName, presetName)
+			}
+
+			if rd.Color != "" {
+				c, err := ParseColor(rd.Color)
+				if err != nil {
+					return nil, fmt.Errorf("invalid preset %s: %v", presetName, err)
+				}
+				p.Colors[&d.Device] = c
+			}
+
+			if len(rd.Channels) > 0 {
+				used := d.Channels()
+				for channel := range rd.Channels {
+					i := sort.Search(len(used), func(i int) bool {
+						return used[i] >= channel
+					})
+					if i == len(used) || used[i] != channel {
+						return nil, fmt.Errorf("channel %v of preset %s is not a channel of device %s", channel, presetName, deviceName)
+					}
+				}
+				p.Channels[&d.Device] = rd.Channels
+			}
+		}
+
+		presets[presetName] = p
+	}
+
+	return presets, nil
+}
+
+// ParseColor parses an 8-bit hex color like "#ff8000" into a 16-bit color.
+func ParseColor(s string) (color.RGBA64, error) {
+	var r, g, b uint8
+	if len(s) != 7 {
+		return color.RGBA64{}, fmt.Errorf("invalid color: %q", s)
+	}
+	if _, err := fmt.Sscanf(s, "#%02x%02x%02x", &r, &g, &b); err != nil {
+		return color.RGBA64{}, fmt.Errorf("invalid color: %q", s)
+	}
+
+	return color.RGBA64{
+		R: uint16(r) * 0x101,
+		G: uint16(g) * 0x101,
+		B: uint16(b) * 0x101,
+		A: 0xffff,
+	}, nil
+}
+
+// transition holds a crossfade between two states.
+type transition struct {
+	// from holds the mode faded from, unless frozen.
+	from string
+	// frozen holds the output faded from if a crossfade got interrupted.
+	frozen map[*dmx.Universe]dmx.DMXFrame
+	// start holds the time the crossfade started.
+	start time.Time
+	// duration holds the duration of the crossfade.
+	duration time.Duration
+}
+
+// progress returns the progress of the crossfade between 0 and 1 at the given time.
+func (t *transition) progress(now time.Time) float64 {
+	elapsed := now.Sub(t.start)
+	if elapsed <= 0 {
+		return 0
+	}
+	if elapsed >= t.duration {
+		return 1
+	}
+
+	return float64(elapsed) / float64(t.duration)
+}
+
+// blend returns the DMX frame between the two frames at the given progress between 0 and 1.
+// The given fine channels are blended together with their coarse channel as 16-bit values.
+func blend(from dmx.DMXFrame, to dmx.DMXFrame, fine map[uint16]uint16, progress float64) dmx.DMXFrame {
+	var frame dmx.DMXFrame
+	for i := range frame {
+		frame[i] = uint8(math.Round(float64(from[i]) + (float64(to[i])-float64(from[i]))*progress))
+	}
+
+	for coarse, f := range fine {
+		a := float64(uint16(from[coarse])<<8 | uint16(from[f]))
+		b := float64(uint16(to[coarse])<<8 | uint16(to[f]))
+		v := uint16(math.Round(a + (b-a)*progress))
+		frame[coarse] = uint8(v >> 8)
+		frame[f] = uint8(v)
+	}
+
+	return frame
+}
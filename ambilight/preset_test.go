@@ -0,0 +1,217 @@
+package ambilight
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/correction"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConstructPresets(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data     *rawConfig
+		Expected func(r *rawConfig) map[string]*Preset
+		Error    error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			actual, err := tc.Data.constructPresets()
+			assert.Equal(t, tc.Error, err)
+			if tc.Expected != nil {
+				assert.Equal(t, tc.Expected(tc.Data), actual)
+			}
+		})
+	}
+
+	devices := func() map[string]*rawDevice {
+		return map[string]*rawDevice{
+			"foo": &rawDevice{
+				Device: dmx.Device{
+					R: 0, G: 1, B: 2,
+					Statics: map[uint16]uint8{
+						3: 255,
+					},
+				},
+			},
+		}
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Data: &rawConfig{
+			Devices: devices(),
+			Presets: map[string]*rawPreset{
+				"warm": &rawPreset{
+					Devices: map[string]*rawPresetDevice{
+						"foo": &rawPresetDevice{
+							Color: "#ff8000",
+							Channels: map[uint16]uint8{
+								3: 128,
+							},
+						},
+					},
+				},
+				"off": &rawPreset{},
+			},
+		},
+		Expected: func(r *rawConfig) map[string]*Preset {
+			foo := &r.Devices["foo"].Device
+
+			return map[string]*Preset{
+				"warm": &Preset{
+					Colors: map[*dmx.Device]color.RGBA64{
+						foo: color.RGBA64{R: 0xffff, G: 0x8080, B: 0, A: 0xffff},
+					},
+					Channels: map[*dmx.Device]map[uint16]uint8{
+						foo: map[uint16]uint8{3: 128},
+					},
+				},
+				"off": &Preset{
+					Colors:   map[*dmx.Device]color.RGBA64{},
+					Channels: map[*dmx.Device]map[uint16]uint8{},
+				},
+			}
+		},
+	})
+	validate(t, &testCase{
+		Name: "Reserved Name",
+
+		Data: &rawConfig{
+			Presets: map[string]*rawPreset{
+				"ambilight": &rawPreset{},
+			},
+		},
+		Error: errors.New("reserved preset name: ambilight"),
+	})
+	validate(t, &testCase{
+		Name: "Unknown Device",
+
+		Data: &rawConfig{
+			Devices: devices(),
+			Presets: map[string]*rawPreset{
+				"warm": &rawPreset{
+					Devices: map[string]*rawPresetDevice{
+						"bar": &rawPresetDevice{},
+					},
+				},
+			},
+		},
+		Error: errors.New("unknown device bar in preset warm"),
+	})
+	validate(t, &testCase{
+		Name: "Invalid Color",
+
+		Data: &rawConfig{
+			Devices: devices(),
+			Presets: map[string]*rawPreset{
+				"warm": &rawPreset{
+					Devices: map[string]*rawPresetDevice{
+						"foo": &rawPresetDevice{Color: "orange"},
+					},
+				},
+			},
+		},
+		Error: errors.New("invalid preset warm: invalid color: \"orange\""),
+	})
+	validate(t, &testCase{
+		Name: "Foreign Channel",
+
+		Data: &rawConfig{
+			Devices: devices(),
+			Presets: map[string]*rawPreset{
+				"warm": &rawPreset{
+					Devices: map[string]*rawPresetDevice{
+						"foo": &rawPresetDevice{
+							Channels: map[uint16]uint8{
+								4: 0,
+							},
+						},
+					},
+				},
+			},
+		},
+		Error: errors.New("channel 4 of preset warm is not a channel of device foo"),
+	})
+}
+
+func TestPresetFrame(t *testing.T) {
+	foo := &dmx.Device{
+		R: 0, G: 1, B: 2,
+		Statics: map[uint16]uint8{
+			3: 255,
+		},
+		Drivers: map[uint16]*dmx.Driver{
+			4: &dmx.Driver{Value: 100},
+			5: &dmx.Driver{Value: 200},
+		},
+		MasterInclude: []uint16{3, 4},
+		RValue:        0x1000,
+	}
+	bar := &dmx.Device{R: 6, G: 7, B: 8, GValue: 0xffff}
+	u := &dmx.Universe{
+		Devices: []*dmx.Device{foo, bar},
+	}
+
+	p := &Preset{
+		Colors: map[*dmx.Device]color.RGBA64{
+			foo: color.RGBA64{R: 0xffff, G: 0x8000, A: 0xffff},
+		},
+		Channels: map[*dmx.Device]map[uint16]uint8{
+			foo: map[uint16]uint8{3: 128, 5: 10},
+		},
+	}
+	corrections := Corrections{
+		foo: correction.Chain{correction.Gain{1, 0.5, 1}},
+	}
+
+	assert.Equal(t, dmx.DMXFrame{0xff, 0x40, 0, 128, 100, 10}, p.frame(u, corrections, 1))
+	assert.Equal(t, dmx.DMXFrame{0x80, 0x20, 0, 64, 50, 10}, p.frame(u, corrections, 0.5))
+
+	// The live values of the devices stay untouched.
+	assert.Equal(t, uint16(0x1000), foo.RValue)
+	assert.Equal(t, uint16(0xffff), bar.GValue)
+	assert.Equal(t, map[uint16]uint8{3: 255}, foo.Statics)
+}
+
+func TestParseColor(t *testing.T) {
+	c, err := ParseColor("#ff8001")
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA64{R: 0xffff, G: 0x8080, B: 0x0101, A: 0xffff}, c)
+
+	for _, s := range []string{"", "ff8001", "#ff80", "#gg8001", "#ff8001ff"} {
+		_, err := ParseColor(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	start := time.Unix(0, 0)
+	tr := &transition{start: start, duration: 2 * time.Second}
+
+	assert.Equal(t, 0.0, tr.progress(start.Add(-time.Second)))
+	assert.Equal(t, 0.25, tr.progress(start.Add(500*time.Millisecond)))
+	assert.Equal(t, 1.0, tr.progress(start.Add(3*time.Second)))
+
+	assert.Equal(t, dmx.DMXFrame{0, 50, 150, 125}, blend(dmx.DMXFrame{0, 0, 200, 100}, dmx.DMXFrame{0, 200, 0, 200}, nil, 0.25))
+
+	// A 16-bit value crossfades monotonically across its coarse and fine channel.
+	fine := map[uint16]uint16{1: 2}
+	from := dmx.DMXFrame{0, 0x10, 0xf0}
+	to := dmx.DMXFrame{0, 0x12, 0x10}
+	previous := uint16(0x10f0)
+	for step := 0; step <= 100; step++ {
+		frame := blend(from, to, fine, float64(step)/100)
+		value := uint16(frame[1])<<8 | uint16(frame[2])
+		assert.GreaterOrEqual(t, value, previous, "step %v", step)
+		previous = value
+	}
+	assert.Equal(t, uint16(0x1210), previous)
+}
@@ -0,0 +1,91 @@
+package ambilight
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/bauersimon/ScreenToArtNet/smoothing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// jsonFields returns the JSON names of the fields of the struct type, skipping the given names.
+func jsonFields(t reflect.Type, skip func(name string) bool) []string {
+	var names []string
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		if f.Anonymous {
+			names = append(names, jsonFields(f.Type, skip)...)
+
+			continue
+		}
+
+		name := f.Name
+		if tag := strings.Split(f.Tag.Get("json"), ",")[0]; tag == "-" {
+			continue
+		} else if tag != "" {
+			name = tag
+		}
+		if skip != nil && skip(name) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+func TestSchema(t *testing.T) {
+	data, err := ioutil.ReadFile("../config.schema.json")
+	require.NoError(t, err)
+
+	type object struct {
+		Properties map[string]json.RawMessage
+	}
+	var schema struct {
+		object
+		Defs map[string]object `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema))
+
+	properties := func(o object) []string {
+		var names []string
+		for name := range o.Properties {
+			if name == "$schema" {
+				continue
+			}
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		return names
+	}
+
+	assert.Equal(t, jsonFields(reflect.TypeOf(rawConfig{}), nil), properties(schema.object))
+	for def, expected := range map[string][]string{
+		"universe":   jsonFields(reflect.TypeOf(dmx.Universe{}), nil),
+		"device":     jsonFields(reflect.TypeOf(rawDevice{}), nil),
+		"driver":     jsonFields(reflect.TypeOf(dmx.Driver{}), nil),
+		"smoothing":  jsonFields(reflect.TypeOf(smoothing.Config{}), nil),
+		"capture":    jsonFields(reflect.TypeOf(capture.AreaConfig{}), nil),
+		"window":     jsonFields(reflect.TypeOf(capture.WindowTarget{}), nil),
+		"correction": jsonFields(reflect.TypeOf(correction.Config{}), nil),
+		"strip":      jsonFields(reflect.TypeOf(rawStrip{}), nil),
+		"preset":     jsonFields(reflect.TypeOf(rawPreset{}), nil),
+		"settings":   jsonFields(reflect.TypeOf(Settings{}), nil),
+	} {
+		assert.Equal(t, expected, properties(schema.Defs[def]), def)
+	}
+}
@@ -0,0 +1,91 @@
+package ambilight
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Settings holds the run settings of the tool, nil settings are not configured.
+type Settings struct {
+	// Src holds the ArtNet source address.
+	Src *string `json:",omitempty"`
+	// Dst holds the ArtNet destination address.
+	Dst *string `json:",omitempty"`
+	// Pause holds the pause between updates in milliseconds.
+	Pause *int `json:",omitempty"`
+	// Screen holds the captured monitor.
+	Screen *int `json:",omitempty"`
+	// Spacing holds the spacing of pixels for averaging.
+	Spacing *int `json:",omitempty"`
+	// Threshold holds the threshold of averaged colors.
+	Threshold *int `json:",omitempty"`
+	// Reducer holds the algorithm reducing an area to a color.
+	Reducer *string `json:",omitempty"`
+
+	// LogLevel holds the log level.
+	LogLevel *string `json:",omitempty"`
+	// LogFormat holds the log format.
+	LogFormat *string `json:",omitempty"`
+
+	// Metrics holds the address of the Prometheus metrics listener.
+	Metrics *string `json:",omitempty"`
+	// Web holds the address of the web interface listener.
+	Web *string `json:",omitempty"`
+	// API holds the address of the control API listener.
+	API *string `json:",omitempty"`
+	// MQTT holds the URL of the MQTT broker.
+	MQTT *string `json:",omitempty"`
+	// MQTTPrefix holds the prefix of the MQTT topics.
+	MQTTPrefix *string `json:",omitempty"`
+	// MQTTFade holds the crossfade time of MQTT mode changes in milliseconds.
+	MQTTFade *int `json:",omitempty"`
+}
+
+// ReadSettings reads the run settings of the given config file and the files it includes.
+func ReadSettings(configPath string) (*Settings, error) {
+	configPath, err := resolveConfigPath(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, _, err := loadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if raw.Settings == nil {
+		return &Settings{}, nil
+	}
+
+	return raw.Settings, nil
+}
+
+// mergeSettings adds the settings of the other configuration, which must not be configured already.
+func (r *rawConfig) mergeSettings(other *rawConfig, otherPath string, declared origins) error {
+	if other.Settings == nil {
+		return nil
+	}
+	if r.Settings == nil {
+		r.Settings = &Settings{}
+	}
+	if declared["Settings"] == nil {
+		declared["Settings"] = map[string]string{}
+	}
+
+	target := reflect.ValueOf(r.Settings).Elem()
+	source := reflect.ValueOf(other.Settings).Elem()
+	for i := 0; i < target.NumField(); i++ {
+		if source.Field(i).IsNil() {
+			continue
+		}
+
+		name := target.Type().Field(i).Name
+		if file, ok := declared["Settings"][name]; ok {
+			return fmt.Errorf("conflicting Settings entry %s in %s and %s", name, file, otherPath)
+		}
+		declared["Settings"][name] = otherPath
+
+		target.Field(i).Set(source.Field(i))
+	}
+
+	return nil
+}
@@ -0,0 +1,275 @@
+package ambilight
+
+import (
+	"fmt"
+	"image"
+	"strings"
+
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/correction"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/bauersimon/ScreenToArtNet/smoothing"
+)
+
+// rawStrip holds a pixel-mapped LED strip that is expanded into one device and one area per pixel.
+type rawStrip struct {
+	// Universe holds the name of the universe the strip starts in.
+	Universe string
+	// Address holds the first channel of the strip.
+	Address uint16
+	// Pixels holds the number of pixels.
+	Pixels int
+	// Order holds the channel order of a pixel, e.g. "RGB", "GRB", "BGR" or "RGBW".
+	Order string
+	// Extraction holds the algorithm deriving the white emitter from the color.
+	Extraction dmx.Extraction `json:",omitempty"`
+
+	// Area holds the name of the area whose edge is sampled.
+	Area string `json:",omitempty"`
+	// Edge holds the sampled edge of the area, one of "top", "bottom", "left" or "right".
+	Edge string `json:",omitempty"`
+	// Line holds the start and end point of a sampled line, used instead of an area edge.
+	Line *[2]image.Point `json:",omitempty"`
+	// Depth holds the size of the sampled pixel areas perpendicular to the edge or line, the whole area if 0 for edges.
+	Depth int
+	// Reverse reverses the direction of the pixels which otherwise run from left to right and top to bottom.
+	Reverse bool
+
+	// Smoothing holds the optional smoothing configuration of all pixel areas.
+	Smoothing *smoothing.Config `json:",omitempty"`
+	// Capture holds the optional capture settings of all pixel areas.
+	Capture *capture.AreaConfig `json:",omitempty"`
+	// Correction holds the optional color correction of all pixels.
+	Correction *correction.Config `json:",omitempty"`
+}
+
+// verify checks if the strip is a valid pixel strip.
+func (s *rawStrip) verify() error {
+	if s.Pixels < 1 {
+		return fmt.Errorf("invalid pixel count (pixels=%v)", s.Pixels)
+	}
+
+	order := strings.ToUpper(s.Order)
+	if len(order) != 3 && len(order) != 4 {
+		return fmt.Errorf("invalid pixel order: %s", s.Order)
+	}
+	for _, c := range "RGB" + order[3:] {
+		if strings.Count(order, string(c)) != 1 || !strings.ContainsRune("RGBW", c) {
+			return fmt.Errorf("invalid pixel order: %s", s.Order)
+		}
+	}
+	if int(s.Address)+len(order) > 512 {
+		return fmt.Errorf("invalid strip address (address=%v)", s.Address)
+	}
+
+	if (s.Edge == "") == (s.Line == nil) {
+		return fmt.Errorf("strip needs either an edge or a line")
+	}
+	switch s.Edge {
+	case "", "top", "bottom", "left", "right":
+	default:
+		return fmt.Errorf("unknown edge: %s", s.Edge)
+	}
+	if s.Depth < 0 {
+		return fmt.Errorf("invalid depth (depth=%v)", s.Depth)
+	}
+
+	return s.Extraction.Verify()
+}
+
+// pixelAreas returns the sampled screen area of every pixel.
+func (s *rawStrip) pixelAreas(area *image.Rectangle) []*image.Rectangle {
+	areas := make([]*image.Rectangle, s.Pixels)
+
+	for i := range areas {
+		var r image.Rectangle
+
+		if s.Line != nil {
+			depth := s.Depth
+			if depth < 1 {
+				depth = 1
+			}
+
+			start, end := s.Line[0], s.Line[1]
+			center := image.Point{
+				X: start.X + (end.X-start.X)*(2*i+1)/(2*s.Pixels),
+				Y: start.Y + (end.Y-start.Y)*(2*i+1)/(2*s.Pixels),
+			}
+			min := center.Sub(image.Point{X: depth / 2, Y: depth / 2})
+			r = image.Rectangle{Min: min, Max: min.Add(image.Point{X: depth, Y: depth})}
+		} else {
+			r = *area
+			depth := s.Depth
+
+			switch s.Edge {
+			case "top", "bottom":
+				r.Min.X, r.Max.X = split(area.Min.X, area.Max.X, i, s.Pixels)
+				if depth > 0 && depth < area.Dy() {
+					if s.Edge == "top" {
+						r.Max.Y = r.Min.Y + depth
+					} else {
+						r.Min.Y = r.Max.Y - depth
+					}
+				}
+			case "left", "right":
+				r.Min.Y, r.Max.Y = split(area.Min.Y, area.Max.Y, i, s.Pixels)
+				if depth > 0 && depth < area.Dx() {
+					if s.Edge == "left" {
+						r.Max.X = r.Min.X + depth
+					} else {
+						r.Min.X = r.Max.X - depth
+					}
+				}
+			}
+		}
+
+		if s.Reverse {
+			areas[s.Pixels-1-i] = &r
+		} else {
+			areas[i] = &r
+		}
+	}
+
+	return areas
+}
+
+// pixelDevice returns the device of a pixel starting at the given channel.
+func (s *rawStrip) pixelDevice(address uint16) *dmx.Device {
+	d := &dmx.Device{
+		Extraction: s.Extraction,
+	}
+
+	for i, c := range strings.ToUpper(s.Order) {
+		channel := address + uint16(i)
+
+		switch c {
+		case 'R':
+			d.R = channel
+		case 'G':
+			d.G = channel
+		case 'B':
+			d.B = channel
+		case 'W':
+			d.W = &channel
+		}
+	}
+
+	return d
+}
+
+// expandStrips adds the areas, devices and, for strips rolling over into further universes, the universes of all pixel strips.
+func (r *rawConfig) expandStrips() error {
+	for _, stripName := range sortedNames(r.Strips) {
+		s := r.Strips[stripName]
+		if err := s.verify(); err != nil {
+			return fmt.Errorf("invalid strip %s: %v", stripName, err)
+		}
+
+		universeName := s.Universe
+		universe, ok := r.Universes[universeName]
+		if !ok {
+			return fmt.Errorf("unknown universe: %s", universeName)
+		}
+
+		var area *image.Rectangle
+		if s.Line == nil {
+			area, ok = r.Areas[s.Area]
+			if !ok {
+				return fmt.Errorf("unknown area: %s", s.Area)
+			}
+		}
+
+		if r.Areas == nil {
+			r.Areas = map[string]*image.Rectangle{}
+		}
+		if r.Devices == nil {
+			r.Devices = map[string]*rawDevice{}
+		}
+		if r.UniversesToDevices == nil {
+			r.UniversesToDevices = map[string][]string{}
+		}
+		if r.AreasToDevices == nil {
+			r.AreasToDevices = map[string][]string{}
+		}
+
+		width := uint16(len(s.Order))
+		address := s.Address
+		for i, pixelArea := range s.pixelAreas(area) {
+			if address+width > 512 {
+				// Roll over into the next universe.
+				previous := universe
+				universeName, universe, ok = r.nextUniverse(previous)
+				if !ok {
+					next := nextPortAddress(previous)
+					universeName = fmt.Sprintf("%s.net%d.subnet%d", stripName, next.Net, next.SubNet)
+					universe = next
+					r.Universes[universeName] = universe
+				}
+				address = 0
+			}
+
+			name := fmt.Sprintf("%s.%d", stripName, i)
+			if _, ok := r.Areas[name]; ok {
+				return fmt.Errorf("area %s of strip %s already exists", name, stripName)
+			}
+			if _, ok := r.Devices[name]; ok {
+				return fmt.Errorf("device %s of strip %s already exists", name, stripName)
+			}
+
+			r.Areas[name] = pixelArea
+			r.Devices[name] = &rawDevice{Device: *s.pixelDevice(address)}
+			r.UniversesToDevices[universeName] = append(r.UniversesToDevices[universeName], name)
+			r.AreasToDevices[name] = []string{name}
+
+			if s.Smoothing != nil {
+				if r.Smoothing == nil {
+					r.Smoothing = map[string]*smoothing.Config{}
+				}
+				r.Smoothing[name] = s.Smoothing
+			}
+			if s.Capture != nil {
+				if r.Capture == nil {
+					r.Capture = map[string]*capture.AreaConfig{}
+				}
+				r.Capture[name] = s.Capture
+			}
+			if s.Correction != nil {
+				if r.Corrections == nil {
+					r.Corrections = map[string]*correction.Config{}
+				}
+				r.Corrections[name] = s.Correction
+			}
+
+			address += width
+		}
+	}
+
+	return nil
+}
+
+// nextUniverse returns the declared universe following the given universe, the first by name if several share its port address.
+func (r *rawConfig) nextUniverse(u *dmx.Universe) (name string, next *dmx.Universe, ok bool) {
+	following := nextPortAddress(u)
+	for _, name := range sortedNames(r.Universes) {
+		next := r.Universes[name]
+		if next.Net == following.Net && next.SubNet == following.SubNet {
+			return name, next, true
+		}
+	}
+
+	return "", nil, false
+}
+
+// nextPortAddress returns a new universe with the port address following the given universe.
+func nextPortAddress(u *dmx.Universe) *dmx.Universe {
+	if u.SubNet < 15 {
+		return &dmx.Universe{Net: u.Net, SubNet: u.SubNet + 1}
+	}
+
+	return &dmx.Universe{Net: u.Net + 1}
+}
+
+// split returns the bounds of the i-th of n equal parts of the given range.
+func split(min int, max int, i int, n int) (int, int) {
+	return min + (max-min)*i/n, min + (max-min)*(i+1)/n
+}
@@ -0,0 +1,315 @@
+package ambilight
+
+import (
+	"image"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStripVerify(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Strip *rawStrip
+		Error string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Strip.verify()
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Strip: &rawStrip{
+			Pixels: 60,
+			Order:  "grbw",
+			Edge:   "top",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Pixels",
+
+		Strip: &rawStrip{
+			Order: "RGB",
+			Edge:  "top",
+		},
+		Error: "invalid pixel count (pixels=0)",
+	})
+	validate(t, &testCase{
+		Name: "Order",
+
+		Strip: &rawStrip{
+			Pixels: 1,
+			Order:  "RRB",
+			Edge:   "top",
+		},
+		Error: "invalid pixel order: RRB",
+	})
+	validate(t, &testCase{
+		Name: "Address",
+
+		Strip: &rawStrip{
+			Address: 510,
+			Pixels:  1,
+			Order:   "RGB",
+			Edge:    "top",
+		},
+		Error: "invalid strip address (address=510)",
+	})
+	validate(t, &testCase{
+		Name: "Edge And Line",
+
+		Strip: &rawStrip{
+			Pixels: 1,
+			Order:  "RGB",
+			Edge:   "top",
+			Line:   &[2]image.Point{},
+		},
+		Error: "strip needs either an edge or a line",
+	})
+	validate(t, &testCase{
+		Name: "Unknown Edge",
+
+		Strip: &rawStrip{
+			Pixels: 1,
+			Order:  "RGB",
+			Edge:   "center",
+		},
+		Error: "unknown edge: center",
+	})
+}
+
+func TestStripPixelAreas(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Strip    *rawStrip
+		Expected []image.Rectangle
+	}
+
+	area := image.Rect(0, 0, 300, 200)
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var actual []image.Rectangle
+			for _, r := range tc.Strip.pixelAreas(&area) {
+				actual = append(actual, *r)
+			}
+			assert.Equal(t, tc.Expected, actual)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Top",
+
+		Strip: &rawStrip{
+			Pixels: 3,
+			Edge:   "top",
+			Depth:  10,
+		},
+		Expected: []image.Rectangle{
+			image.Rect(0, 0, 100, 10),
+			image.Rect(100, 0, 200, 10),
+			image.Rect(200, 0, 300, 10),
+		},
+	})
+	validate(t, &testCase{
+		Name: "Right Reversed",
+
+		Strip: &rawStrip{
+			Pixels:  2,
+			Edge:    "right",
+			Depth:   10,
+			Reverse: true,
+		},
+		Expected: []image.Rectangle{
+			image.Rect(290, 100, 300, 200),
+			image.Rect(290, 0, 300, 100),
+		},
+	})
+	validate(t, &testCase{
+		Name: "Whole Area",
+
+		Strip: &rawStrip{
+			Pixels: 2,
+			Edge:   "left",
+		},
+		Expected: []image.Rectangle{
+			image.Rect(0, 0, 300, 100),
+			image.Rect(0, 100, 300, 200),
+		},
+	})
+	validate(t, &testCase{
+		Name: "Line",
+
+		Strip: &rawStrip{
+			Pixels: 2,
+			Line:   &[2]image.Point{{X: 0, Y: 100}, {X: 200, Y: 100}},
+			Depth:  10,
+		},
+		Expected: []image.Rectangle{
+			image.Rect(45, 95, 55, 105),
+			image.Rect(145, 95, 155, 105),
+		},
+	})
+}
+
+func TestExpandStrips(t *testing.T) {
+	t.Run("Roll-Over", func(t *testing.T) {
+		raw := &rawConfig{
+			Areas: map[string]*image.Rectangle{
+				"screen": &image.Rectangle{
+					Max: image.Point{X: 1720, Y: 100},
+				},
+			},
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{
+					SubNet: 15,
+				},
+			},
+			Strips: map[string]*rawStrip{
+				"strip": &rawStrip{
+					Universe: "u1",
+					Address:  6,
+					Pixels:   172,
+					Order:    "GRB",
+					Area:     "screen",
+					Edge:     "bottom",
+					Depth:    20,
+				},
+			},
+		}
+
+		assert.NoError(t, raw.expandStrips())
+
+		assert.Len(t, raw.UniversesToDevices["u1"], 168)
+		assert.Equal(t, []string{"strip.168", "strip.169", "strip.170", "strip.171"}, raw.UniversesToDevices["strip.net1.subnet0"])
+		assert.Equal(t, &dmx.Universe{Net: 1, SubNet: 0}, raw.Universes["strip.net1.subnet0"])
+
+		assert.Equal(t, dmx.Device{R: 7, G: 6, B: 8}, raw.Devices["strip.0"].Device)
+		assert.Equal(t, dmx.Device{R: 508, G: 507, B: 509}, raw.Devices["strip.167"].Device)
+		assert.Equal(t, dmx.Device{R: 1, G: 0, B: 2}, raw.Devices["strip.168"].Device)
+
+		assert.Equal(t, &image.Rectangle{Min: image.Point{X: 0, Y: 80}, Max: image.Point{X: 10, Y: 100}}, raw.Areas["strip.0"])
+		assert.Equal(t, []string{"strip.0"}, raw.AreasToDevices["strip.0"])
+
+		universes, err := raw.constructUniverses()
+		assert.NoError(t, err)
+		assert.Len(t, universes, 2)
+	})
+	t.Run("Declared Universe", func(t *testing.T) {
+		raw := &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+				"u2": &dmx.Universe{
+					SubNet: 1,
+				},
+			},
+			Strips: map[string]*rawStrip{
+				"strip": &rawStrip{
+					Universe: "u1",
+					Address:  508,
+					Pixels:   2,
+					Order:    "RGBW",
+					Line:     &[2]image.Point{{X: 0, Y: 0}, {X: 10, Y: 0}},
+				},
+			},
+		}
+
+		assert.NoError(t, raw.expandStrips())
+
+		white := uint16(511)
+		assert.Equal(t, dmx.Device{R: 508, G: 509, B: 510, W: &white}, raw.Devices["strip.0"].Device)
+		assert.Equal(t, []string{"strip.1"}, raw.UniversesToDevices["u2"])
+		assert.Len(t, raw.Universes, 2)
+	})
+	t.Run("Shared Port Address", func(t *testing.T) {
+		for i := 0; i < 10; i++ {
+			raw := &rawConfig{
+				Universes: map[string]*dmx.Universe{
+					"u1": &dmx.Universe{},
+					"u2": &dmx.Universe{SubNet: 1},
+					"u3": &dmx.Universe{SubNet: 1},
+				},
+			}
+
+			name, _, ok := raw.nextUniverse(raw.Universes["u1"])
+			assert.True(t, ok)
+			assert.Equal(t, "u2", name)
+		}
+	})
+	t.Run("Capture", func(t *testing.T) {
+		raw := &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+			},
+			Strips: map[string]*rawStrip{
+				"strip": &rawStrip{
+					Universe: "u1",
+					Pixels:   2,
+					Order:    "RGB",
+					Line:     &[2]image.Point{{X: 0, Y: 0}, {X: 10, Y: 0}},
+					Capture: &capture.AreaConfig{
+						Reducer: capture.ReducerMedian,
+					},
+				},
+			},
+		}
+
+		assert.NoError(t, raw.expandStrips())
+
+		assert.Equal(t, map[string]*capture.AreaConfig{
+			"strip.0": &capture.AreaConfig{Reducer: capture.ReducerMedian},
+			"strip.1": &capture.AreaConfig{Reducer: capture.ReducerMedian},
+		}, raw.Capture)
+	})
+	t.Run("Unknown Area", func(t *testing.T) {
+		raw := &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+			},
+			Strips: map[string]*rawStrip{
+				"strip": &rawStrip{
+					Universe: "u1",
+					Pixels:   1,
+					Order:    "RGB",
+					Area:     "screen",
+					Edge:     "top",
+				},
+			},
+		}
+
+		assert.EqualError(t, raw.expandStrips(), "unknown area: screen")
+	})
+	t.Run("Name Collision", func(t *testing.T) {
+		raw := &rawConfig{
+			Universes: map[string]*dmx.Universe{
+				"u1": &dmx.Universe{},
+			},
+			Devices: map[string]*rawDevice{
+				"strip.0": &rawDevice{},
+			},
+			Strips: map[string]*rawStrip{
+				"strip": &rawStrip{
+					Universe: "u1",
+					Pixels:   1,
+					Order:    "RGB",
+					Line:     &[2]image.Point{},
+				},
+			},
+		}
+
+		assert.EqualError(t, raw.expandStrips(), "device strip.0 of strip strip already exists")
+	})
+}
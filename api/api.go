@@ -0,0 +1,256 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+)
+
+// Controller controls a running ambilight, e.g. an *ambilight.Ambilight extended by a reload.
+type Controller interface {
+	// Stats returns the current running statistics.
+	Stats() ambilight.Stats
+	// Pause suspends the updates until Resume is called.
+	Pause()
+	// Resume continues the updates after a Pause.
+	Resume()
+	// SetBlackout sets whether all channels are sent as zero.
+	SetBlackout(blackout bool)
+	// FadeMaster fades the master intensity to the given value between 0 and 1 over the given duration.
+	FadeMaster(master float64, fade time.Duration) error
+	// SetMode crossfades over the given duration to the mode, either ambilight.ModeAmbilight or the name of a preset.
+	SetMode(mode string, fade time.Duration) error
+	// Modes returns all modes.
+	Modes() []string
+	// SetOverride sets a static color for the named device, or removes it if the color is nil.
+	SetOverride(device string, c *color.RGBA64) error
+	// Reload reloads the configuration.
+	Reload() error
+}
+
+// Handler serves the JSON control API of an ambilight.
+type Handler struct {
+	// controller holds the controlled ambilight.
+	controller Controller
+	// logger holds the logger of the API.
+	logger *slog.Logger
+
+	// mux holds the routes of the API.
+	mux *http.ServeMux
+}
+
+// NewHandler returns a new API handler for the given controller.
+func NewHandler(controller Controller, logger *slog.Logger) *Handler {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	h := &Handler{
+		controller: controller,
+		logger:     logger,
+
+		mux: http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/status", h.method(http.MethodGet, h.serveStatus))
+	h.mux.HandleFunc("/pause", h.method(http.MethodPost, h.servePause))
+	h.mux.HandleFunc("/resume", h.method(http.MethodPost, h.serveResume))
+	h.mux.HandleFunc("/blackout", h.method(http.MethodPost, h.serveBlackout))
+	h.mux.HandleFunc("/master", h.method(http.MethodPut, h.serveMaster))
+	h.mux.HandleFunc("/mode", h.method(http.MethodPut, h.serveMode))
+	h.mux.HandleFunc("/modes", h.method(http.MethodGet, h.serveModes))
+	h.mux.HandleFunc("/devices/", h.serveDevice)
+	h.mux.HandleFunc("/reload", h.method(http.MethodPost, h.serveReload))
+
+	return h
+}
+
+// ServeHTTP serves the API.
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// method restricts the handler to the given request method.
+func (h *Handler) method(method string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+func (h *Handler) serveStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+func (h *Handler) servePause(w http.ResponseWriter, r *http.Request) {
+	h.controller.Pause()
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+func (h *Handler) serveResume(w http.ResponseWriter, r *http.Request) {
+	h.controller.Resume()
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+// blackoutRequest holds the body of a blackout request.
+type blackoutRequest struct {
+	// Blackout is set to send all channels as zero.
+	Blackout bool
+}
+
+func (h *Handler) serveBlackout(w http.ResponseWriter, r *http.Request) {
+	var request blackoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	h.controller.SetBlackout(request.Blackout)
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+// masterRequest holds the body of a master request.
+type masterRequest struct {
+	// Master holds the master intensity between 0 and 1.
+	Master *float64
+	// Fade holds the fade time in milliseconds.
+	Fade int
+}
+
+func (h *Handler) serveMaster(w http.ResponseWriter, r *http.Request) {
+	var request masterRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+	if request.Master == nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("missing master intensity"))
+
+		return
+	}
+
+	if err := h.controller.FadeMaster(*request.Master, time.Duration(request.Fade)*time.Millisecond); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+// modeRequest holds the body of a mode request.
+type modeRequest struct {
+	// Mode holds the mode, either "ambilight" or the name of a preset.
+	Mode string
+	// Fade holds the crossfade time in milliseconds.
+	Fade int
+}
+
+func (h *Handler) serveMode(w http.ResponseWriter, r *http.Request) {
+	var request modeRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+
+	if err := h.controller.SetMode(request.Mode, time.Duration(request.Fade)*time.Millisecond); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+
+		return
+	}
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+func (h *Handler) serveModes(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.controller.Modes())
+}
+
+// overrideRequest holds the body of an override request.
+type overrideRequest struct {
+	// Color holds the static color as 8-bit hex color, e.g. "#ff8000".
+	Color string
+}
+
+// serveDevice serves "/devices/<name>/override", which sets the static color with PUT and removes it with DELETE.
+func (h *Handler) serveDevice(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/devices/"), "/override")
+	if !ok || name == "" || strings.Contains(name, "/") {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		var request overrideRequest
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+
+			return
+		}
+		c, err := ambilight.ParseColor(request.Color)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+
+			return
+		}
+
+		if err := h.controller.SetOverride(name, &c); err != nil {
+			writeError(w, http.StatusNotFound, err)
+
+			return
+		}
+	case http.MethodDelete:
+		if err := h.controller.SetOverride(name, nil); err != nil {
+			writeError(w, http.StatusNotFound, err)
+
+			return
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method not allowed: %s", r.Method))
+
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) serveReload(w http.ResponseWriter, r *http.Request) {
+	if err := h.controller.Reload(); err != nil {
+		h.logger.Error("reloading configuration failed", "error", err)
+		writeError(w, http.StatusInternalServerError, err)
+
+		return
+	}
+
+	writeJSON(w, http.StatusOK, h.controller.Stats())
+}
+
+// errorResponse holds the body of a failed request.
+type errorResponse struct {
+	// Error holds the error message.
+	Error string
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorResponse{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,332 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeController records the calls made to it.
+type fakeController struct {
+	stats     ambilight.Stats
+	fade      time.Duration
+	overrides map[string]*color.RGBA64
+	reloads   int
+	reloadErr error
+}
+
+func (c *fakeController) Stats() ambilight.Stats {
+	return c.stats
+}
+
+func (c *fakeController) Pause() {
+	c.stats.Paused = true
+}
+
+func (c *fakeController) Resume() {
+	c.stats.Paused = false
+}
+
+func (c *fakeController) SetBlackout(blackout bool) {
+	c.stats.Blackout = blackout
+}
+
+func (c *fakeController) FadeMaster(master float64, fade time.Duration) error {
+	if master < 0 || master > 1 {
+		return fmt.Errorf("invalid master intensity (master=%v)", master)
+	}
+	c.stats.MasterTarget = master
+	c.fade = fade
+
+	return nil
+}
+
+func (c *fakeController) SetMode(mode string, fade time.Duration) error {
+	if mode != ambilight.ModeAmbilight && mode != "warm" {
+		return fmt.Errorf("unknown mode: %s", mode)
+	}
+	c.stats.Mode = mode
+	c.fade = fade
+
+	return nil
+}
+
+func (c *fakeController) Modes() []string {
+	return []string{ambilight.ModeAmbilight, "warm"}
+}
+
+func (c *fakeController) SetOverride(device string, color *color.RGBA64) error {
+	if device != "par" {
+		return fmt.Errorf("unknown device: %s", device)
+	}
+	c.overrides[device] = color
+
+	return nil
+}
+
+func (c *fakeController) Reload() error {
+	c.reloads++
+
+	return c.reloadErr
+}
+
+func TestHandler(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Controller *fakeController
+		Method     string
+		Path       string
+		Body       string
+
+		ExpectedStatus     int
+		ExpectedBody       string
+		ExpectedController *fakeController
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			if tc.Controller == nil {
+				tc.Controller = &fakeController{
+					stats:     ambilight.Stats{Running: true, Master: 1},
+					overrides: map[string]*color.RGBA64{},
+				}
+			}
+			server := httptest.NewServer(NewHandler(tc.Controller, nil))
+			defer server.Close()
+
+			request, err := http.NewRequest(tc.Method, server.URL+tc.Path, strings.NewReader(tc.Body))
+			require.NoError(t, err)
+			response, err := server.Client().Do(request)
+			require.NoError(t, err)
+			defer response.Body.Close()
+
+			body, err := ioutil.ReadAll(response.Body)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.ExpectedStatus, response.StatusCode)
+			assert.Equal(t, tc.ExpectedBody, string(body))
+			if tc.ExpectedController != nil {
+				assert.Equal(t, tc.ExpectedController, tc.Controller)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Status",
+
+		Method: http.MethodGet,
+		Path:   "/status",
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":false,"Master":1,"MasterTarget":0,"Mode":""}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Wrong Method",
+
+		Method: http.MethodPost,
+		Path:   "/status",
+
+		ExpectedStatus: http.StatusMethodNotAllowed,
+		ExpectedBody:   `{"Error":"method not allowed: POST"}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Pause",
+
+		Method: http.MethodPost,
+		Path:   "/pause",
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":true,"Blackout":false,"Master":1,"MasterTarget":0,"Mode":""}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Resume",
+
+		Controller: &fakeController{
+			stats: ambilight.Stats{Running: true, Paused: true, Master: 1},
+		},
+		Method: http.MethodPost,
+		Path:   "/resume",
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":false,"Master":1,"MasterTarget":0,"Mode":""}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Blackout",
+
+		Method: http.MethodPost,
+		Path:   "/blackout",
+		Body:   `{"Blackout": true}`,
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":true,"Master":1,"MasterTarget":0,"Mode":""}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Master",
+
+		Method: http.MethodPut,
+		Path:   "/master",
+		Body:   `{"Master": 0.25, "Fade": 1500}`,
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":false,"Master":1,"MasterTarget":0.25,"Mode":""}` + "\n",
+		ExpectedController: &fakeController{
+			stats:     ambilight.Stats{Running: true, Master: 1, MasterTarget: 0.25},
+			fade:      1500 * time.Millisecond,
+			overrides: map[string]*color.RGBA64{},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Master Missing",
+
+		Method: http.MethodPut,
+		Path:   "/master",
+		Body:   `{}`,
+
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"Error":"missing master intensity"}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Master Invalid",
+
+		Method: http.MethodPut,
+		Path:   "/master",
+		Body:   `{"Master": 2}`,
+
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"Error":"invalid master intensity (master=2)"}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Mode",
+
+		Method: http.MethodPut,
+		Path:   "/mode",
+		Body:   `{"Mode": "warm", "Fade": 2000}`,
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":false,"Master":1,"MasterTarget":0,"Mode":"warm"}` + "\n",
+		ExpectedController: &fakeController{
+			stats:     ambilight.Stats{Running: true, Master: 1, Mode: "warm"},
+			fade:      2 * time.Second,
+			overrides: map[string]*color.RGBA64{},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Mode",
+
+		Method: http.MethodPut,
+		Path:   "/mode",
+		Body:   `{"Mode": "cold"}`,
+
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"Error":"unknown mode: cold"}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Modes",
+
+		Method: http.MethodGet,
+		Path:   "/modes",
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `["ambilight","warm"]` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Override",
+
+		Method: http.MethodPut,
+		Path:   "/devices/par/override",
+		Body:   `{"Color": "#ff8001"}`,
+
+		ExpectedStatus: http.StatusNoContent,
+		ExpectedBody:   "",
+		ExpectedController: &fakeController{
+			stats: ambilight.Stats{Running: true, Master: 1},
+			overrides: map[string]*color.RGBA64{
+				"par": &color.RGBA64{R: 0xffff, G: 0x8080, B: 0x0101, A: 0xffff},
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Override Invalid Color",
+
+		Method: http.MethodPut,
+		Path:   "/devices/par/override",
+		Body:   `{"Color": "red"}`,
+
+		ExpectedStatus: http.StatusBadRequest,
+		ExpectedBody:   `{"Error":"invalid color: \"red\""}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Override Unknown Device",
+
+		Method: http.MethodPut,
+		Path:   "/devices/foo/override",
+		Body:   `{"Color": "#000000"}`,
+
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   `{"Error":"unknown device: foo"}` + "\n",
+	})
+	validate(t, &testCase{
+		Name: "Override Removed",
+
+		Controller: &fakeController{
+			overrides: map[string]*color.RGBA64{
+				"par": &color.RGBA64{},
+			},
+		},
+		Method: http.MethodDelete,
+		Path:   "/devices/par/override",
+
+		ExpectedStatus: http.StatusNoContent,
+		ExpectedBody:   "",
+		ExpectedController: &fakeController{
+			overrides: map[string]*color.RGBA64{
+				"par": nil,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Device Route",
+
+		Method: http.MethodPut,
+		Path:   "/devices/par",
+
+		ExpectedStatus: http.StatusNotFound,
+		ExpectedBody:   "404 page not found\n",
+	})
+	validate(t, &testCase{
+		Name: "Reload",
+
+		Method: http.MethodPost,
+		Path:   "/reload",
+
+		ExpectedStatus: http.StatusOK,
+		ExpectedBody:   `{"Frames":0,"Rate":0,"Running":true,"Paused":false,"Blackout":false,"Master":1,"MasterTarget":0,"Mode":""}` + "\n",
+		ExpectedController: &fakeController{
+			stats:     ambilight.Stats{Running: true, Master: 1},
+			overrides: map[string]*color.RGBA64{},
+			reloads:   1,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Reload Failed",
+
+		Controller: &fakeController{
+			reloadErr: errors.New("unknown area: foo"),
+		},
+		Method: http.MethodPost,
+		Path:   "/reload",
+
+		ExpectedStatus: http.StatusInternalServerError,
+		ExpectedBody:   `{"Error":"unknown area: foo"}` + "\n",
+	})
+}
@@ -5,8 +5,13 @@ import (
 	"image"
 	"image/color"
 	"image/png"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/kbinani/screenshot"
 )
@@ -15,11 +20,36 @@ import (
 type Screen struct {
 	// Areas holds the screen areas.
 	Areas []*image.Rectangle
-	// Borders holds the capturing borders.
+	// Borders holds the capturing borders of the default monitor.
 	Borders image.Rectangle
 
 	// Config holds the configuration for the screen capture.
 	Config CaptureConfig
+
+	// regions holds the regions captured per frame.
+	regions []region
+	// sources holds where the areas are found in the captured regions.
+	sources []source
+	// desktop holds the rectangle spanning all monitors, captured windows are clipped to it.
+	desktop image.Rectangle
+
+	// lock guards the target window state, which both the analysis and the screenshots locate.
+	lock sync.Mutex
+	// window holds the target window found last, nil if it is missing.
+	window *Window
+	// searched holds the time of the last search for the target window.
+	searched time.Time
+
+	// durations holds the time spent on the phases of the last analysis.
+	durations Durations
+}
+
+// Durations holds the time spent on the phases of an analysis.
+type Durations struct {
+	// Capture holds the time spent capturing the screen.
+	Capture time.Duration
+	// Average holds the time spent averaging the screen tiles.
+	Average time.Duration
 }
 
 // CaptureConfig holds the configuration for the screen capture.
@@ -28,73 +58,313 @@ type CaptureConfig struct {
 	Spacing int
 	// Threshold holds the averaged color threshold.
 	Threshold int
+	// Reducer holds the algorithm reducing a tile to a color, empty averages.
+	Reducer Reducer
+	// Areas holds the settings overriding the defaults per area.
+	Areas map[*image.Rectangle]AreaConfig
 
-	// Monitor holds the monitor used for capture.
+	// Monitor holds the default monitor used for capture.
 	Monitor int
+	// Window holds the window the areas without their own monitor are relative to, nil captures the default monitor.
+	Window *WindowTarget
+	// Finder locates the target window.
+	Finder WindowFinder
+
+	// Logger holds the logger of the screen capture, nil logs to the default logger.
+	Logger *slog.Logger
+}
+
+// AreaConfig holds the capture settings of an area, zero values fall back to the defaults of the capture configuration.
+type AreaConfig struct {
+	// Spacing holds the averaging spacing.
+	Spacing int `json:",omitempty"`
+	// Threshold holds the averaged color threshold.
+	Threshold *int `json:",omitempty"`
+	// Reducer holds the algorithm reducing the area to a color.
+	Reducer Reducer `json:",omitempty"`
+	// Monitor holds the monitor the area is relative to.
+	Monitor *Monitor `json:",omitempty"`
+}
+
+// Verify checks if the AreaConfig is valid.
+func (c *AreaConfig) Verify() error {
+	if c.Spacing < 0 {
+		return fmt.Errorf("invalid spacing (spacing=%v)", c.Spacing)
+	}
+	if c.Threshold != nil && (*c.Threshold < 0 || *c.Threshold > 255) {
+		return fmt.Errorf("invalid threshold (threshold=%v)", *c.Threshold)
+	}
+
+	if c.Monitor != nil {
+		if err := c.Monitor.Verify(); err != nil {
+			return err
+		}
+	}
+
+	return c.Reducer.Verify()
+}
+
+// area returns the effective settings of the given area.
+func (c *CaptureConfig) area(area *image.Rectangle) (spacing int, threshold int, reducer Reducer) {
+	spacing, threshold, reducer = c.Spacing, c.Threshold, c.Reducer
+
+	override, ok := c.Areas[area]
+	if !ok {
+		return spacing, threshold, reducer
+	}
+	if override.Spacing > 0 {
+		spacing = override.Spacing
+	}
+	if override.Threshold != nil {
+		threshold = *override.Threshold
+	}
+	if override.Reducer != "" {
+		reducer = override.Reducer
+	}
+
+	return spacing, threshold, reducer
+}
+
+// Monitors returns the bounds of the active monitors.
+func Monitors() []image.Rectangle {
+	bounds := make([]image.Rectangle, screenshot.NumActiveDisplays())
+	for i := range bounds {
+		bounds[i] = screenshot.GetDisplayBounds(i)
+	}
+
+	return bounds
 }
 
 // NewScreen returns a new screen, tiled with the given configuration.
-func NewScreen(areas []*image.Rectangle, config CaptureConfig) *Screen {
-	return &Screen{
+// Every monitor needed by the areas is captured once per frame.
+func NewScreen(areas []*image.Rectangle, config CaptureConfig) (*Screen, error) {
+	if config.Logger == nil {
+		config.Logger = slog.Default()
+	}
+
+	if config.Window != nil {
+		if err := config.Window.Verify(); err != nil {
+			return nil, err
+		}
+		if config.Finder == nil {
+			return nil, fmt.Errorf("window capture needs a window finder")
+		}
+	}
+
+	monitors := Monitors()
+	regions, sources, err := plan(areas, config, monitors)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Screen{
 		Areas:   areas,
 		Borders: screenshot.GetDisplayBounds(config.Monitor),
 		Config:  config,
+		regions: regions,
+		sources: sources,
+	}
+	for _, m := range monitors {
+		s.desktop = s.desktop.Union(m)
+	}
+
+	for _, r := range regions {
+		if r.Window {
+			config.Logger.Info("window selected", "target", config.Window.String())
+		} else {
+			config.Logger.Info("screen selected", "monitor", r.Monitor.String(), "bounds", r.Bounds.String())
+		}
 	}
+	config.Logger.Info("screen tiled", "areas", len(areas), "monitors", len(regions))
+
+	return s, nil
 }
 
-func (s *Screen) capture() (areas []*image.RGBA, monitor *image.RGBA, err error) {
-	monitor, err = screenshot.CaptureRect(s.Borders)
-	if err != nil {
-		return nil, nil, err
+// VerifyAreas checks if the areas are within the active monitors they are captured from.
+// Areas relative to the target window are only located while capturing.
+func VerifyAreas(areas []*image.Rectangle, config CaptureConfig) error {
+	_, _, err := plan(areas, config, Monitors())
+
+	return err
+}
+
+func (s *Screen) capture() (areas []*image.RGBA, captures []*image.RGBA, err error) {
+	captures = make([]*image.RGBA, len(s.regions))
+	for i, r := range s.regions {
+		captures[i], err = s.captureRegion(r)
+		if err != nil {
+			return nil, nil, err
+		}
 	}
 
 	areas = make([]*image.RGBA, len(s.Areas))
-	for i, b := range s.Areas {
-		areas[i] = monitor.SubImage(*b).(*image.RGBA)
+	for i, source := range s.sources {
+		areas[i] = captures[source.Region].SubImage(source.Rect).(*image.RGBA)
 	}
 
-	return areas, monitor, nil
+	return areas, captures, nil
 }
 
-// GetColors returns an averaged color per screen tile.
-func (s *Screen) GetColors() ([]color.RGBA, error) {
-	var colors []color.RGBA
+// captureRegion captures the region, a missing target window results in an empty capture.
+func (s *Screen) captureRegion(r region) (*image.RGBA, error) {
+	if !r.Window {
+		return screenshot.CaptureRect(r.Bounds)
+	}
 
-	areas, _, err := s.capture()
+	bounds, ok, err := s.locate(time.Now())
 	if err != nil {
 		return nil, err
 	}
+	clipped := bounds.Intersect(s.desktop)
+	if !ok || clipped.Empty() {
+		return &image.RGBA{}, nil
+	}
+
+	window, err := screenshot.CaptureRect(clipped)
+	if err != nil {
+		return nil, err
+	}
+	// Keep the coordinates relative to the window if parts of it are outside of the desktop.
+	window.Rect = window.Rect.Add(clipped.Min.Sub(bounds.Min))
+
+	return window, nil
+}
 
-	for _, a := range areas {
-		c, err := averageRGBA(a, s.Config.Spacing, s.Config.Threshold)
+// Screenshot returns a capture of the whole screen, or of the target window if there is one.
+func (s *Screen) Screenshot() (*image.RGBA, error) {
+	if s.Config.Window != nil {
+		window, err := s.captureRegion(region{Window: true})
 		if err != nil {
 			return nil, err
 		}
+		if window.Rect.Empty() {
+			return nil, s.windowNotFound()
+		}
+
+		return window, nil
+	}
+
+	return screenshot.CaptureRect(s.Borders)
+}
+
+// Size returns the size of the screenshots without capturing, the target window is located if there is one.
+func (s *Screen) Size() (image.Point, error) {
+	if s.Config.Window == nil {
+		return s.Borders.Size(), nil
+	}
 
-		colors = append(colors, c)
+	bounds, ok, err := s.locate(time.Now())
+	if err != nil {
+		return image.Point{}, err
+	}
+	if !ok {
+		return image.Point{}, s.windowNotFound()
+	}
+
+	return bounds.Size(), nil
+}
+
+// windowNotFound returns the error of a missing target window.
+func (s *Screen) windowNotFound() error {
+	return fmt.Errorf("window not found (%s)", s.Config.Window)
+}
+
+// Analysis holds the measures of a screen tile.
+type Analysis struct {
+	// Area holds the analyzed screen tile.
+	Area *image.Rectangle
+
+	// Color holds the averaged 16-bit color.
+	Color color.RGBA64
+	// Luma holds the average luma between 0 and 1.
+	Luma float64
+	// Max holds the luma of the brightest pixel between 0 and 1.
+	Max float64
+	// CentroidX holds the horizontal position of the luma weighted centroid relative to the tile between 0 and 1.
+	CentroidX float64
+	// CentroidY holds the vertical position of the luma weighted centroid relative to the tile between 0 and 1.
+	CentroidY float64
+}
+
+// GetColors returns an averaged 16-bit color per screen tile.
+func (s *Screen) GetColors() ([]color.RGBA64, error) {
+	analyses, err := s.Analyze()
+	if err != nil {
+		return nil, err
+	}
+
+	colors := make([]color.RGBA64, len(analyses))
+	for i, a := range analyses {
+		colors[i] = a.Color
 	}
 
 	return colors, nil
 }
 
+// Analyze returns the measures per screen tile.
+func (s *Screen) Analyze() ([]Analysis, error) {
+	var analyses []Analysis
+
+	start := time.Now()
+	areas, _, err := s.capture()
+	if err != nil {
+		return nil, err
+	}
+	captured := time.Now()
+	s.durations.Capture = captured.Sub(start)
+
+	for i, a := range areas {
+		spacing, threshold, reducer := s.Config.area(s.Areas[i])
+		analysis, err := analyze(a, spacing, threshold, reducer)
+		if err != nil {
+			return nil, err
+		}
+		analysis.Area = s.Areas[i]
+
+		analyses = append(analyses, analysis)
+	}
+	s.durations.Average = time.Since(captured)
+
+	return analyses, nil
+}
+
+// LastDurations returns the time spent on the phases of the last analysis.
+func (s *Screen) LastDurations() Durations {
+	return s.durations
+}
+
 // SavePreview saves the current capture configurations as multiple ".png" images at the given path.
-func (s *Screen) SavePreview(dst string) error {
-	areas, monitor, err := s.capture()
+// The images of the areas are named after the given area names.
+func (s *Screen) SavePreview(dst string, names map[*image.Rectangle]string) error {
+	areas, captures, err := s.capture()
 	if err != nil {
 		return err
 	}
+	for i, c := range captures {
+		if s.regions[i].Window && c.Rect.Empty() {
+			return s.windowNotFound()
+		}
+	}
 
 	if err := os.MkdirAll(dst, 0755); err != nil {
 		return err
 	}
 
-	err = saveArea(filepath.Join(dst, "monitor.png"), monitor)
-	if err != nil {
-		return err
+	for i, c := range captures {
+		file := "monitor.png"
+		if s.regions[i].Window {
+			file = "window.png"
+		} else if len(captures) > 1 {
+			file = "monitor-" + s.regions[i].Monitor.String() + ".png"
+		}
+		err = saveArea(filepath.Join(dst, file), c)
+		if err != nil {
+			return err
+		}
 	}
 
 	for i, a := range areas {
-		err = saveArea(filepath.Join(dst, fmt.Sprintf("area%d.png", i)), a)
+		err = saveArea(filepath.Join(dst, previewFile(i, s.Areas[i], names)), a)
 		if err != nil {
 			return err
 		}
@@ -103,6 +373,16 @@ func (s *Screen) SavePreview(dst string) error {
 	return nil
 }
 
+// previewFile returns the file name of the preview of an area, falling back to its index for unnamed areas.
+func previewFile(index int, area *image.Rectangle, names map[*image.Rectangle]string) string {
+	name, ok := names[area]
+	if !ok || name == "" {
+		return fmt.Sprintf("area%d.png", index)
+	}
+
+	return "area-" + strings.NewReplacer("/", "_", "\\", "_").Replace(name) + ".png"
+}
+
 func saveArea(dst string, area *image.RGBA) error {
 	outputFile, err := os.Create(dst)
 	if err != nil {
@@ -117,44 +397,63 @@ func saveArea(dst string, area *image.RGBA) error {
 	return outputFile.Close()
 }
 
-func averageRGBA(area *image.RGBA, space int, threshold int) (color.RGBA, error) {
-	var r uint64
-	var g uint64
-	var b uint64
-
-	var count uint64 = 1
+func analyze(area *image.RGBA, space int, threshold int, reducer Reducer) (Analysis, error) {
+	var lumaSum float64
+	var lumaMax float64
+	var lumaX float64
+	var lumaY float64
+	var samples float64
 
 	if space < 1 {
-		return color.RGBA{}, fmt.Errorf("invalid spacing for averaging (%v)", space)
+		return Analysis{}, fmt.Errorf("invalid spacing for averaging (%v)", space)
 	}
 	if threshold < 0 || threshold > 255 {
-		return color.RGBA{}, fmt.Errorf("invalid threshold for averaging (%v)", threshold)
+		return Analysis{}, fmt.Errorf("invalid threshold for averaging (%v)", threshold)
+	}
+	if err := reducer.Verify(); err != nil {
+		return Analysis{}, err
 	}
+	colors := reducer.newAccumulator()
+	defer release(colors)
+
+	width := float64(area.Rect.Dx())
+	height := float64(area.Rect.Dy())
 
 	for x := area.Rect.Min.X; x < area.Rect.Max.X; x = x + space {
 		for y := area.Rect.Min.Y; y < area.Rect.Max.Y; y = y + space {
-			pixel := color.RGBAModel.Convert(area.At(x, y)).(color.RGBA)
-			lr := pixel.R
-			lg := pixel.G
-			lb := pixel.B
+			// Sum up the 16-bit representation so the average keeps the fractional part.
+			lr, lg, lb, _ := area.At(x, y).RGBA()
+
+			luma := (0.2126*float64(lr) + 0.7152*float64(lg) + 0.0722*float64(lb)) / 0xffff
+			lumaSum += luma
+			lumaMax = math.Max(lumaMax, luma)
+			lumaX += luma * (float64(x-area.Rect.Min.X) + 0.5) / width
+			lumaY += luma * (float64(y-area.Rect.Min.Y) + 0.5) / height
+			samples++
 
-			average := (uint32(lr) + uint32(lg) + uint32(lb)) / 3
-			if average < uint32(threshold) {
+			average := (lr + lg + lb) / 3
+			if average < uint32(threshold)*0x101 {
 				continue
 			}
 
-			r = r + uint64(lr)
-			g = g + uint64(lg)
-			b = b + uint64(lb)
-
-			count++
+			colors.add(lr, lg, lb)
 		}
 	}
 
-	return color.RGBA{
-		R: uint8(r / count),
-		G: uint8(g / count),
-		B: uint8(b / count),
-		A: 255,
-	}, nil
+	// Without pixels above the threshold the color is black.
+	analysis := Analysis{
+		Color:     colors.color(),
+		Max:       lumaMax,
+		CentroidX: 0.5,
+		CentroidY: 0.5,
+	}
+	if samples > 0 {
+		analysis.Luma = lumaSum / samples
+	}
+	if lumaSum > 0 {
+		analysis.CentroidX = lumaX / lumaSum
+		analysis.CentroidY = lumaY / lumaSum
+	}
+
+	return analysis, nil
 }
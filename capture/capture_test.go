@@ -2,11 +2,14 @@ package capture
 
 import (
 	"image"
+	"image/color"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func BenchmarkCapture(b *testing.B) {
-	s := NewScreen(
+	s, err := NewScreen(
 		[]*image.Rectangle{
 			&image.Rectangle{
 				Min: image.Point{0, 0},
@@ -17,6 +20,9 @@ func BenchmarkCapture(b *testing.B) {
 			Monitor: 1,
 		},
 	)
+	if err != nil {
+		b.Skip(err)
+	}
 
 	spacings := map[string]int{
 		"dense":   1,
@@ -33,3 +39,180 @@ func BenchmarkCapture(b *testing.B) {
 		})
 	}
 }
+
+func TestAnalyze(t *testing.T) {
+	area := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	area.Set(0, 0, color.RGBA{R: 1, G: 0, B: 255, A: 255})
+	area.Set(1, 0, color.RGBA{R: 2, G: 0, B: 255, A: 255})
+
+	a, err := analyze(area, 1, 0, ReducerMean)
+	assert.NoError(t, err)
+
+	// The 16-bit average keeps what 8 bits would round away.
+	assert.Equal(t, color.RGBA64{R: 0x181, G: 0, B: 0xffff, A: 0xffff}, a.Color)
+
+	a, err = analyze(area, 1, 255, ReducerMean)
+	assert.NoError(t, err)
+	assert.Equal(t, color.RGBA64{A: 0xffff}, a.Color)
+
+	t.Run("Measures", func(t *testing.T) {
+		area := image.NewRGBA(image.Rect(10, 10, 14, 12))
+		for x := 10; x < 14; x++ {
+			for y := 10; y < 12; y++ {
+				area.Set(x, y, color.RGBA{A: 255})
+			}
+		}
+		area.Set(13, 11, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+		a, err := analyze(area, 1, 0, ReducerMean)
+		assert.NoError(t, err)
+
+		assert.InDelta(t, 1.0/8, a.Luma, 1e-9)
+		assert.InDelta(t, 1.0, a.Max, 1e-9)
+		assert.InDelta(t, 3.5/4, a.CentroidX, 1e-9)
+		assert.InDelta(t, 1.5/2, a.CentroidY, 1e-9)
+	})
+	t.Run("Black", func(t *testing.T) {
+		a, err := analyze(image.NewRGBA(image.Rect(0, 0, 2, 2)), 1, 0, ReducerMean)
+		assert.NoError(t, err)
+
+		assert.Equal(t, Analysis{
+			Color:     color.RGBA64{A: 0xffff},
+			CentroidX: 0.5,
+			CentroidY: 0.5,
+		}, a)
+	})
+}
+
+func TestPreviewFile(t *testing.T) {
+	left := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	unnamed := &image.Rectangle{Max: image.Point{X: 20, Y: 10}}
+	nested := &image.Rectangle{Max: image.Point{X: 30, Y: 10}}
+	names := map[*image.Rectangle]string{
+		left:   "left",
+		nested: "tv/top",
+	}
+
+	assert.Equal(t, "area-left.png", previewFile(0, left, names))
+	assert.Equal(t, "area1.png", previewFile(1, unnamed, names))
+	assert.Equal(t, "area-tv_top.png", previewFile(2, nested, names))
+}
+
+func TestAnalyzeReducers(t *testing.T) {
+	// Three dark red pixels and a bright white subtitle pixel.
+	area := image.NewRGBA(image.Rect(0, 0, 4, 1))
+	area.Set(0, 0, color.RGBA{R: 100, A: 255})
+	area.Set(1, 0, color.RGBA{R: 102, A: 255})
+	area.Set(2, 0, color.RGBA{R: 104, A: 255})
+	area.Set(3, 0, color.RGBA{R: 255, G: 255, B: 255, A: 255})
+
+	type testCase struct {
+		Name string
+
+		Reducer Reducer
+
+		ExpectedColor color.RGBA64
+		ExpectedError string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			a, err := analyze(area, 1, 0, tc.Reducer)
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.ExpectedColor, a.Color)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Default",
+
+		ExpectedColor: color.RGBA64{R: 0x8ccc, G: 0x3fff, B: 0x3fff, A: 0xffff},
+	})
+	validate(t, &testCase{
+		Name: "Mean",
+
+		Reducer: ReducerMean,
+
+		ExpectedColor: color.RGBA64{R: 0x8ccc, G: 0x3fff, B: 0x3fff, A: 0xffff},
+	})
+	validate(t, &testCase{
+		Name: "Median",
+
+		Reducer: ReducerMedian,
+
+		ExpectedColor: color.RGBA64{R: 0x6868, A: 0xffff},
+	})
+	validate(t, &testCase{
+		Name: "Dominant",
+
+		Reducer: ReducerDominant,
+
+		ExpectedColor: color.RGBA64{R: 0x6666, A: 0xffff},
+	})
+	validate(t, &testCase{
+		Name: "Unknown",
+
+		Reducer: "mode",
+
+		ExpectedError: "unknown reducer: mode",
+	})
+}
+
+func TestMedianAccumulator(t *testing.T) {
+	a := ReducerMedian.newAccumulator()
+	assert.Equal(t, color.RGBA64{A: 0xffff}, a.color())
+
+	for _, v := range []uint32{0x1000, 0x80ff, 0xffff, 0x8000, 0x0000} {
+		a.add(v, 0xffff-v, 0x4242)
+	}
+	assert.Equal(t, color.RGBA64{R: 0x8080, G: 0x7f7f, B: 0x4242, A: 0xffff}, a.color())
+	release(a)
+
+	// Released histograms start empty again.
+	a = ReducerMedian.newAccumulator()
+	a.add(0xffff, 0xffff, 0xffff)
+	assert.Equal(t, color.RGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff}, a.color())
+}
+
+func TestCaptureConfigArea(t *testing.T) {
+	edge := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	center := &image.Rectangle{Max: image.Point{X: 20, Y: 10}}
+	other := &image.Rectangle{Max: image.Point{X: 30, Y: 10}}
+	zero := 0
+
+	config := CaptureConfig{
+		Spacing:   4,
+		Threshold: 10,
+		Reducer:   ReducerMean,
+		Areas: map[*image.Rectangle]AreaConfig{
+			edge: AreaConfig{
+				Spacing: 1,
+			},
+			center: AreaConfig{
+				Threshold: &zero,
+				Reducer:   ReducerDominant,
+			},
+		},
+	}
+
+	spacing, threshold, reducer := config.area(edge)
+	assert.Equal(t, 1, spacing)
+	assert.Equal(t, 10, threshold)
+	assert.Equal(t, ReducerMean, reducer)
+
+	spacing, threshold, reducer = config.area(center)
+	assert.Equal(t, 4, spacing)
+	assert.Equal(t, 0, threshold)
+	assert.Equal(t, ReducerDominant, reducer)
+
+	spacing, threshold, reducer = config.area(other)
+	assert.Equal(t, 4, spacing)
+	assert.Equal(t, 10, threshold)
+	assert.Equal(t, ReducerMean, reducer)
+}
@@ -0,0 +1,162 @@
+package capture
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"strconv"
+)
+
+// Monitor identifies a captured monitor by its index.
+type Monitor int
+
+// MonitorAll captures the virtual desktop spanning all monitors, areas on it use desktop coordinates.
+const MonitorAll Monitor = -1
+
+// String returns the index of the monitor or "all".
+func (m Monitor) String() string {
+	if m == MonitorAll {
+		return "all"
+	}
+
+	return strconv.Itoa(int(m))
+}
+
+// MarshalJSON encodes the monitor as its index or "all".
+func (m Monitor) MarshalJSON() ([]byte, error) {
+	if m == MonitorAll {
+		return json.Marshal(m.String())
+	}
+
+	return json.Marshal(int(m))
+}
+
+// UnmarshalJSON decodes the monitor from its index or "all".
+func (m *Monitor) UnmarshalJSON(data []byte) error {
+	var all string
+	if err := json.Unmarshal(data, &all); err == nil {
+		if all != "all" {
+			return fmt.Errorf("unknown monitor: %s", all)
+		}
+		*m = MonitorAll
+
+		return nil
+	}
+
+	var index int
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("invalid monitor: %s", data)
+	}
+	*m = Monitor(index)
+
+	return nil
+}
+
+// Verify checks if the Monitor is an index or "all".
+func (m Monitor) Verify() error {
+	if m < MonitorAll {
+		return fmt.Errorf("invalid monitor (monitor=%v)", int(m))
+	}
+
+	return nil
+}
+
+// region holds a rectangle of the desktop captured once per frame.
+type region struct {
+	// Monitor holds the captured monitor.
+	Monitor Monitor
+	// Window captures the target window instead of a monitor, its bounds are located per frame.
+	Window bool
+	// Bounds holds the captured rectangle in desktop coordinates.
+	Bounds image.Rectangle
+}
+
+// source holds where an area is found in the captures of a frame.
+type source struct {
+	// Region holds the index of the captured region.
+	Region int
+	// Rect holds the area within the capture of the region.
+	Rect image.Rectangle
+}
+
+// plan returns the regions of the given monitor bounds that the areas need, each captured once, and where every area is found in them.
+// Areas without their own monitor are relative to the target window if there is one, all other areas have to be within their monitor.
+func plan(areas []*image.Rectangle, config CaptureConfig, monitors []image.Rectangle) (regions []region, sources []source, err error) {
+	indexes := map[Monitor]int{}
+	window := -1
+	sources = make([]source, len(areas))
+	for i, a := range areas {
+		override, ok := config.Areas[a]
+		if config.Window != nil && (!ok || override.Monitor == nil) {
+			if window < 0 {
+				window = len(regions)
+				regions = append(regions, region{
+					Window: true,
+				})
+			}
+			sources[i] = source{
+				Region: window,
+				Rect:   *a,
+			}
+
+			continue
+		}
+
+		monitor := Monitor(config.Monitor)
+		if ok && override.Monitor != nil {
+			monitor = *override.Monitor
+		}
+
+		index, ok := indexes[monitor]
+		if !ok {
+			bounds, err := monitorBounds(monitor, monitors)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			index = len(regions)
+			indexes[monitor] = index
+			regions = append(regions, region{
+				Monitor: monitor,
+				Bounds:  bounds,
+			})
+		}
+
+		// Captures start at the origin, areas of single monitors already are relative to their monitor.
+		rect := *a
+		if monitor == MonitorAll {
+			rect = rect.Sub(regions[index].Bounds.Min)
+		}
+		if size := regions[index].Bounds.Size(); !rect.In(image.Rectangle{Max: size}) {
+			return nil, nil, fmt.Errorf("area %v exceeds the monitor (monitor=%v, bounds=%v)", a, monitor, regions[index].Bounds)
+		}
+		sources[i] = source{
+			Region: index,
+			Rect:   rect,
+		}
+	}
+
+	return regions, sources, nil
+}
+
+// monitorBounds returns the desktop rectangle of the monitor.
+func monitorBounds(monitor Monitor, monitors []image.Rectangle) (image.Rectangle, error) {
+	if monitor == MonitorAll {
+		if len(monitors) == 0 {
+			return image.Rectangle{}, fmt.Errorf("no monitors found")
+		}
+
+		var bounds image.Rectangle
+		for _, m := range monitors {
+			bounds = bounds.Union(m)
+		}
+
+		return bounds, nil
+	}
+
+	if monitor < 0 || int(monitor) >= len(monitors) {
+		return image.Rectangle{}, fmt.Errorf("unknown monitor (monitor=%v)", monitor)
+	}
+
+	return monitors[monitor], nil
+}
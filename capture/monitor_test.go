@@ -0,0 +1,250 @@
+package capture
+
+import (
+	"encoding/json"
+	"image"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMonitorJSON(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Data          string
+		Expected      Monitor
+		ExpectedError string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var m Monitor
+			err := json.Unmarshal([]byte(tc.Data), &m)
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, m)
+
+			data, err := json.Marshal(m)
+			assert.NoError(t, err)
+			assert.JSONEq(t, tc.Data, string(data))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Index",
+
+		Data:     `1`,
+		Expected: 1,
+	})
+	validate(t, &testCase{
+		Name: "All",
+
+		Data:     `"all"`,
+		Expected: MonitorAll,
+	})
+	validate(t, &testCase{
+		Name: "Unknown Name",
+
+		Data:          `"primary"`,
+		ExpectedError: "unknown monitor: primary",
+	})
+	validate(t, &testCase{
+		Name: "Invalid",
+
+		Data:          `1.5`,
+		ExpectedError: "invalid monitor: 1.5",
+	})
+}
+
+func TestPlan(t *testing.T) {
+	monitors := []image.Rectangle{
+		image.Rect(0, 0, 1920, 1080),
+		image.Rect(1920, 0, 4480, 1440),
+	}
+	projector := Monitor(1)
+	all := MonitorAll
+	unknown := Monitor(2)
+
+	tv := &image.Rectangle{Max: image.Point{X: 100, Y: 1080}}
+	screen := &image.Rectangle{Max: image.Point{X: 2560, Y: 100}}
+	desktop := &image.Rectangle{Min: image.Point{X: 1820, Y: 0}, Max: image.Point{X: 2020, Y: 100}}
+
+	type testCase struct {
+		Name string
+
+		Areas  []*image.Rectangle
+		Config CaptureConfig
+
+		ExpectedRegions []region
+		ExpectedSources []source
+		ExpectedError   string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			regions, sources, err := plan(tc.Areas, tc.Config, monitors)
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.ExpectedRegions, regions)
+			assert.Equal(t, tc.ExpectedSources, sources)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Default Monitor",
+
+		Areas: []*image.Rectangle{tv, screen},
+		Config: CaptureConfig{
+			Monitor: 1,
+		},
+
+		ExpectedRegions: []region{
+			{Monitor: 1, Bounds: monitors[1]},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *tv},
+			{Region: 0, Rect: *screen},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Multiple Monitors",
+
+		Areas: []*image.Rectangle{tv, screen, desktop},
+		Config: CaptureConfig{
+			Areas: map[*image.Rectangle]AreaConfig{
+				screen:  AreaConfig{Monitor: &projector},
+				desktop: AreaConfig{Monitor: &all},
+			},
+		},
+
+		ExpectedRegions: []region{
+			{Monitor: 0, Bounds: monitors[0]},
+			{Monitor: 1, Bounds: monitors[1]},
+			{Monitor: MonitorAll, Bounds: image.Rect(0, 0, 4480, 1440)},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *tv},
+			{Region: 1, Rect: *screen},
+			{Region: 2, Rect: *desktop},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Desktop Offset",
+
+		Areas: []*image.Rectangle{desktop},
+		Config: CaptureConfig{
+			Monitor: 1,
+			Areas: map[*image.Rectangle]AreaConfig{
+				desktop: AreaConfig{Monitor: &all},
+			},
+		},
+
+		ExpectedRegions: []region{
+			{Monitor: MonitorAll, Bounds: image.Rect(0, 0, 4480, 1440)},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *desktop},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Window",
+
+		Areas: []*image.Rectangle{tv, screen, desktop},
+		Config: CaptureConfig{
+			Window: &WindowTarget{Class: "vlc"},
+			Areas: map[*image.Rectangle]AreaConfig{
+				screen: AreaConfig{Monitor: &projector},
+			},
+		},
+
+		ExpectedRegions: []region{
+			{Window: true},
+			{Monitor: 1, Bounds: monitors[1]},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *tv},
+			{Region: 1, Rect: *screen},
+			{Region: 0, Rect: *desktop},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Monitor",
+
+		Areas: []*image.Rectangle{tv},
+		Config: CaptureConfig{
+			Areas: map[*image.Rectangle]AreaConfig{
+				tv: AreaConfig{Monitor: &unknown},
+			},
+		},
+
+		ExpectedError: "unknown monitor (monitor=2)",
+	})
+	validate(t, &testCase{
+		Name: "Exceeding Monitor",
+
+		Areas: []*image.Rectangle{screen},
+		Config: CaptureConfig{
+			Monitor: 0,
+		},
+
+		ExpectedError: "area (0,0)-(2560,100) exceeds the monitor (monitor=0, bounds=(0,0)-(1920,1080))",
+	})
+	validate(t, &testCase{
+		Name: "Spanning Monitors",
+
+		Areas: []*image.Rectangle{screen},
+		Config: CaptureConfig{
+			Areas: map[*image.Rectangle]AreaConfig{
+				screen: AreaConfig{Monitor: &all},
+			},
+			Monitor: 0,
+		},
+
+		ExpectedRegions: []region{
+			{Monitor: MonitorAll, Bounds: image.Rect(0, 0, 4480, 1440)},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *screen},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Window Areas Unchecked",
+
+		Areas: []*image.Rectangle{screen},
+		Config: CaptureConfig{
+			Window: &WindowTarget{Class: "vlc"},
+		},
+
+		ExpectedRegions: []region{
+			{Window: true},
+		},
+		ExpectedSources: []source{
+			{Region: 0, Rect: *screen},
+		},
+	})
+
+	t.Run("Negative Desktop", func(t *testing.T) {
+		regions, sources, err := plan([]*image.Rectangle{desktop}, CaptureConfig{
+			Areas: map[*image.Rectangle]AreaConfig{
+				desktop: AreaConfig{Monitor: &all},
+			},
+		}, []image.Rectangle{
+			image.Rect(-1920, 0, 0, 1080),
+			image.Rect(0, 0, 2560, 1440),
+		})
+		assert.NoError(t, err)
+
+		assert.Equal(t, image.Rect(-1920, 0, 2560, 1440), regions[0].Bounds)
+		assert.Equal(t, image.Rect(3740, 0, 3940, 100), sources[0].Rect)
+	})
+}
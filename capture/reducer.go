@@ -0,0 +1,166 @@
+package capture
+
+import (
+	"fmt"
+	"image/color"
+	"sync"
+)
+
+// Reducer identifies the algorithm reducing the pixels of a screen tile to a single color.
+type Reducer string
+
+const (
+	// ReducerMean averages the pixels.
+	ReducerMean Reducer = "mean"
+	// ReducerMedian takes the median of every color channel, ignoring small highlights like subtitles.
+	ReducerMedian Reducer = "median"
+	// ReducerDominant averages the pixels of the most frequent coarse color.
+	ReducerDominant Reducer = "dominant"
+)
+
+// Verify checks if the Reducer is a known algorithm, the empty reducer averages.
+func (r Reducer) Verify() error {
+	switch r {
+	case "", ReducerMean, ReducerMedian, ReducerDominant:
+		return nil
+	}
+
+	return fmt.Errorf("unknown reducer: %s", r)
+}
+
+// accumulator collects the 16-bit pixels of a tile and reduces them to a color.
+type accumulator interface {
+	// add adds a pixel.
+	add(r uint32, g uint32, b uint32)
+	// color returns the reduced color, black without pixels.
+	color() color.RGBA64
+}
+
+// newAccumulator returns the accumulator of the reducer.
+func (r Reducer) newAccumulator() accumulator {
+	switch r {
+	case ReducerMedian:
+		a := medianAccumulators.Get().(*medianAccumulator)
+		*a = medianAccumulator{}
+
+		return a
+	case ReducerDominant:
+		return &dominantAccumulator{bins: map[uint32]*meanAccumulator{}}
+	default:
+		return &meanAccumulator{}
+	}
+}
+
+// meanAccumulator averages the pixels.
+type meanAccumulator struct {
+	r     uint64
+	g     uint64
+	b     uint64
+	count uint64
+}
+
+func (a *meanAccumulator) add(r uint32, g uint32, b uint32) {
+	a.r += uint64(r)
+	a.g += uint64(g)
+	a.b += uint64(b)
+	a.count++
+}
+
+func (a *meanAccumulator) color() color.RGBA64 {
+	c := color.RGBA64{A: 0xffff}
+	if a.count > 0 {
+		c.R = uint16(a.r / a.count)
+		c.G = uint16(a.g / a.count)
+		c.B = uint16(a.b / a.count)
+	}
+
+	return c
+}
+
+// release returns the buffers of the accumulator for the next tile, it must not be used afterwards.
+func release(a accumulator) {
+	if m, ok := a.(*medianAccumulator); ok {
+		medianAccumulators.Put(m)
+	}
+}
+
+// medianAccumulators holds the histograms of released median accumulators.
+var medianAccumulators = sync.Pool{
+	New: func() any {
+		return &medianAccumulator{}
+	},
+}
+
+// medianAccumulator takes the median of every color channel.
+// The pixels are counted in a histogram of the upper 8 bits per channel, so the memory stays fixed however large the tile is.
+type medianAccumulator struct {
+	histograms [3][256]uint32
+	count      uint32
+}
+
+func (a *medianAccumulator) add(r uint32, g uint32, b uint32) {
+	a.histograms[0][r>>8]++
+	a.histograms[1][g>>8]++
+	a.histograms[2][b>>8]++
+	a.count++
+}
+
+func (a *medianAccumulator) color() color.RGBA64 {
+	var medians [3]uint16
+	if a.count > 0 {
+		for i := range a.histograms {
+			medians[i] = uint16(a.median(&a.histograms[i])) * 0x101
+		}
+	}
+
+	return color.RGBA64{R: medians[0], G: medians[1], B: medians[2], A: 0xffff}
+}
+
+// median returns the bin of the upper median of the histogram.
+func (a *medianAccumulator) median(histogram *[256]uint32) int {
+	var seen uint32
+	for bin, count := range histogram {
+		seen += count
+		if seen > a.count/2 {
+			return bin
+		}
+	}
+
+	return len(histogram) - 1
+}
+
+// dominantBits holds the bits per channel of the coarse colors of the dominant reducer.
+const dominantBits = 4
+
+// dominantAccumulator averages the pixels of the most frequent coarse color.
+type dominantAccumulator struct {
+	bins map[uint32]*meanAccumulator
+}
+
+func (a *dominantAccumulator) add(r uint32, g uint32, b uint32) {
+	shift := 16 - dominantBits
+	key := r>>shift<<(2*dominantBits) | g>>shift<<dominantBits | b>>shift
+
+	bin, ok := a.bins[key]
+	if !ok {
+		bin = &meanAccumulator{}
+		a.bins[key] = bin
+	}
+	bin.add(r, g, b)
+}
+
+func (a *dominantAccumulator) color() color.RGBA64 {
+	var dominant *meanAccumulator
+	var dominantKey uint32
+	for key, bin := range a.bins {
+		// Ties go to the lower coarse color to stay deterministic.
+		if dominant == nil || bin.count > dominant.count || bin.count == dominant.count && key < dominantKey {
+			dominant, dominantKey = bin, key
+		}
+	}
+	if dominant == nil {
+		return color.RGBA64{A: 0xffff}
+	}
+
+	return dominant.color()
+}
@@ -0,0 +1,132 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"strings"
+	"time"
+)
+
+// Window holds a top-level window of the desktop.
+type Window struct {
+	// ID holds the identifier of the window for the window finder.
+	ID uint32
+	// Title holds the title of the window.
+	Title string
+	// Instance holds the instance name of the window class.
+	Instance string
+	// Class holds the class name of the window.
+	Class string
+	// Process holds the name of the process owning the window.
+	Process string
+
+	// Bounds holds the rectangle of the window in desktop coordinates.
+	Bounds image.Rectangle
+}
+
+// WindowFinder locates the windows of the desktop.
+type WindowFinder interface {
+	// Windows returns the visible top-level windows, topmost first.
+	Windows() ([]Window, error)
+	// Bounds returns the current rectangle of the window in desktop coordinates, or an error if it is not visible anymore.
+	Bounds(id uint32) (image.Rectangle, error)
+}
+
+// WindowTarget selects the captured window by its title, class or process, all given criteria have to match.
+type WindowTarget struct {
+	// Title holds a part of the window title.
+	Title string `json:",omitempty"`
+	// Class holds the instance or class name of the window, ignoring case.
+	Class string `json:",omitempty"`
+	// Process holds the name of the process owning the window, ignoring case.
+	Process string `json:",omitempty"`
+}
+
+// Verify checks if the WindowTarget has a criterion.
+func (t *WindowTarget) Verify() error {
+	if t.Title == "" && t.Class == "" && t.Process == "" {
+		return fmt.Errorf("window needs a title, class or process")
+	}
+
+	return nil
+}
+
+// Matches returns if the window satisfies all criteria of the target.
+func (t *WindowTarget) Matches(w *Window) bool {
+	if t.Title != "" && !strings.Contains(w.Title, t.Title) {
+		return false
+	}
+	if t.Class != "" && !strings.EqualFold(w.Class, t.Class) && !strings.EqualFold(w.Instance, t.Class) {
+		return false
+	}
+	if t.Process != "" && !strings.EqualFold(w.Process, t.Process) {
+		return false
+	}
+
+	return true
+}
+
+// String returns the criteria of the target.
+func (t *WindowTarget) String() string {
+	var criteria []string
+	if t.Title != "" {
+		criteria = append(criteria, fmt.Sprintf("title=%q", t.Title))
+	}
+	if t.Class != "" {
+		criteria = append(criteria, fmt.Sprintf("class=%q", t.Class))
+	}
+	if t.Process != "" {
+		criteria = append(criteria, fmt.Sprintf("process=%q", t.Process))
+	}
+
+	return strings.Join(criteria, " ")
+}
+
+// windowSearchInterval holds the minimum time between searches for a missing window.
+const windowSearchInterval = time.Second
+
+// locate returns the current rectangle of the target window, following the window found last while it stays visible.
+// Without a matching window it returns false and searches again at most once per search interval.
+func (s *Screen) locate(now time.Time) (image.Rectangle, bool, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.window != nil {
+		bounds, err := s.Config.Finder.Bounds(s.window.ID)
+		if err == nil && !bounds.Empty() {
+			if bounds != s.window.Bounds {
+				s.Config.Logger.Debug("window moved", "window", s.window.Title, "bounds", bounds.String())
+				s.window.Bounds = bounds
+			}
+
+			return bounds, true, nil
+		}
+
+		s.Config.Logger.Warn("window lost", "window", s.window.Title)
+		s.window = nil
+	}
+
+	first := s.searched.IsZero()
+	if !first && now.Sub(s.searched) < windowSearchInterval {
+		return image.Rectangle{}, false, nil
+	}
+	s.searched = now
+
+	windows, err := s.Config.Finder.Windows()
+	if err != nil {
+		return image.Rectangle{}, false, err
+	}
+	for _, w := range windows {
+		if s.Config.Window.Matches(&w) && !w.Bounds.Empty() {
+			s.window = &w
+			s.Config.Logger.Info("window found", "window", w.Title, "class", w.Class, "process", w.Process, "bounds", w.Bounds.String())
+
+			return w.Bounds, true, nil
+		}
+	}
+	if first {
+		s.Config.Logger.Warn("window not found", "target", s.Config.Window.String())
+	}
+
+	return image.Rectangle{}, false, nil
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || freebsd || netbsd || openbsd)
+
+package capture
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// NewWindowFinder returns an error since window capture is only supported on X11 desktops.
+func NewWindowFinder() (WindowFinder, error) {
+	return nil, fmt.Errorf("window capture is not supported on %s", runtime.GOOS)
+}
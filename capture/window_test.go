@@ -0,0 +1,218 @@
+package capture
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeFinder holds a fixed set of windows.
+type fakeFinder struct {
+	windows []Window
+
+	// searches holds the number of calls to Windows.
+	searches int
+}
+
+func (f *fakeFinder) Windows() ([]Window, error) {
+	f.searches++
+
+	return f.windows, nil
+}
+
+func (f *fakeFinder) Bounds(id uint32) (image.Rectangle, error) {
+	for _, w := range f.windows {
+		if w.ID == id {
+			return w.Bounds, nil
+		}
+	}
+
+	return image.Rectangle{}, fmt.Errorf("window is not viewable (window=%v)", id)
+}
+
+func TestWindowTargetMatches(t *testing.T) {
+	w := &Window{
+		Title:    "Big Buck Bunny - VLC media player",
+		Instance: "vlc",
+		Class:    "Vlc",
+		Process:  "vlc",
+	}
+
+	type testCase struct {
+		Name string
+
+		Target   WindowTarget
+		Expected bool
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Target.Matches(w))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Title",
+
+		Target:   WindowTarget{Title: "VLC media player"},
+		Expected: true,
+	})
+	validate(t, &testCase{
+		Name: "Class",
+
+		Target:   WindowTarget{Class: "VLC"},
+		Expected: true,
+	})
+	validate(t, &testCase{
+		Name: "Process",
+
+		Target:   WindowTarget{Process: "vlc"},
+		Expected: true,
+	})
+	validate(t, &testCase{
+		Name: "All Criteria",
+
+		Target:   WindowTarget{Title: "Bunny", Class: "vlc", Process: "mpv"},
+		Expected: false,
+	})
+	validate(t, &testCase{
+		Name: "Title Case",
+
+		Target:   WindowTarget{Title: "vlc media player"},
+		Expected: false,
+	})
+}
+
+func TestWindowTargetVerify(t *testing.T) {
+	assert.NoError(t, (&WindowTarget{Class: "vlc"}).Verify())
+	assert.EqualError(t, (&WindowTarget{}).Verify(), "window needs a title, class or process")
+}
+
+func TestLocate(t *testing.T) {
+	finder := &fakeFinder{
+		windows: []Window{
+			{ID: 1, Title: "Terminal", Bounds: image.Rect(0, 0, 800, 600)},
+			{ID: 2, Title: "Game", Bounds: image.Rect(100, 100, 1380, 820)},
+			{ID: 3, Title: "Game Launcher", Bounds: image.Rect(0, 0, 400, 300)},
+		},
+	}
+	s := &Screen{
+		Config: CaptureConfig{
+			Window: &WindowTarget{Title: "Game"},
+			Finder: finder,
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		},
+	}
+	now := time.Now()
+
+	// The topmost matching window is followed.
+	bounds, ok, err := s.locate(now)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(100, 100, 1380, 820), bounds)
+	assert.Equal(t, 1, finder.searches)
+
+	// A moved window is located again without searching.
+	finder.windows[1].Bounds = image.Rect(200, 100, 1480, 820)
+	bounds, ok, err = s.locate(now)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(200, 100, 1480, 820), bounds)
+	assert.Equal(t, 1, finder.searches)
+
+	// A lost window is searched again.
+	finder.windows = finder.windows[:1]
+	_, ok, err = s.locate(now.Add(windowSearchInterval))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, finder.searches)
+
+	// Missing windows are searched at most once per interval.
+	_, ok, err = s.locate(now.Add(windowSearchInterval + time.Millisecond))
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, 2, finder.searches)
+
+	finder.windows = append(finder.windows, Window{ID: 4, Title: "Game", Bounds: image.Rect(0, 0, 1280, 720)})
+	bounds, ok, err = s.locate(now.Add(2 * windowSearchInterval))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, image.Rect(0, 0, 1280, 720), bounds)
+	assert.Equal(t, 3, finder.searches)
+}
+
+func TestWindowNotFound(t *testing.T) {
+	s := &Screen{
+		Config: CaptureConfig{
+			Window: &WindowTarget{Title: "Game"},
+			Finder: &fakeFinder{},
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		},
+		regions: []region{{Window: true}},
+	}
+
+	_, err := s.Screenshot()
+	assert.EqualError(t, err, `window not found (title="Game")`)
+	_, err = s.Size()
+	assert.EqualError(t, err, `window not found (title="Game")`)
+	assert.EqualError(t, s.SavePreview(t.TempDir(), nil), `window not found (title="Game")`)
+}
+
+func TestLocateConcurrently(t *testing.T) {
+	s := &Screen{
+		Config: CaptureConfig{
+			Window: &WindowTarget{Title: "Game"},
+			Finder: &lockedFinder{
+				finder: &fakeFinder{
+					windows: []Window{
+						{ID: 1, Title: "Game", Bounds: image.Rect(0, 0, 1280, 720)},
+					},
+				},
+			},
+			Logger: slog.New(slog.NewTextHandler(io.Discard, nil)),
+		},
+	}
+
+	// The analysis and the screenshots of the web page locate the window from different goroutines.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				bounds, ok, err := s.locate(time.Now())
+				assert.NoError(t, err)
+				assert.True(t, ok)
+				assert.Equal(t, image.Rect(0, 0, 1280, 720), bounds)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// lockedFinder holds a window finder that is safe for concurrent use.
+type lockedFinder struct {
+	lock   sync.Mutex
+	finder *fakeFinder
+}
+
+func (f *lockedFinder) Windows() ([]Window, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.finder.Windows()
+}
+
+func (f *lockedFinder) Bounds(id uint32) (image.Rectangle, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	return f.finder.Bounds(id)
+}
@@ -0,0 +1,187 @@
+//go:build linux || freebsd || netbsd || openbsd
+
+package capture
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/xgb"
+	"github.com/BurntSushi/xgb/xproto"
+)
+
+// x11Finder locates the windows of an X11 desktop through the properties the window manager maintains.
+type x11Finder struct {
+	conn *xgb.Conn
+	root xproto.Window
+
+	// lock guards the interned atoms.
+	lock  sync.Mutex
+	atoms map[string]xproto.Atom
+}
+
+// NewWindowFinder returns a window finder connected to the X11 display of the DISPLAY environment variable.
+func NewWindowFinder() (WindowFinder, error) {
+	conn, err := xgb.NewConn()
+	if err != nil {
+		return nil, fmt.Errorf("cannot connect to the X11 display: %v", err)
+	}
+
+	return &x11Finder{
+		conn:  conn,
+		root:  xproto.Setup(conn).DefaultScreen(conn).Root,
+		atoms: map[string]xproto.Atom{},
+	}, nil
+}
+
+// Windows returns the visible top-level windows, topmost first.
+func (f *x11Finder) Windows() ([]Window, error) {
+	ids, err := f.clients()
+	if err != nil {
+		return nil, err
+	}
+
+	var windows []Window
+	for i := len(ids) - 1; i >= 0; i-- {
+		bounds, err := f.Bounds(uint32(ids[i]))
+		if err != nil {
+			continue
+		}
+
+		instance, class := f.class(ids[i])
+		windows = append(windows, Window{
+			ID:       uint32(ids[i]),
+			Title:    f.title(ids[i]),
+			Instance: instance,
+			Class:    class,
+			Process:  f.process(ids[i]),
+			Bounds:   bounds,
+		})
+	}
+
+	return windows, nil
+}
+
+// Bounds returns the current rectangle of the window in root window coordinates.
+func (f *x11Finder) Bounds(id uint32) (image.Rectangle, error) {
+	window := xproto.Window(id)
+
+	attributes, err := xproto.GetWindowAttributes(f.conn, window).Reply()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	if attributes.MapState != xproto.MapStateViewable {
+		return image.Rectangle{}, fmt.Errorf("window is not viewable (window=%v)", id)
+	}
+
+	geometry, err := xproto.GetGeometry(f.conn, xproto.Drawable(window)).Reply()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+	origin, err := xproto.TranslateCoordinates(f.conn, window, f.root, 0, 0).Reply()
+	if err != nil {
+		return image.Rectangle{}, err
+	}
+
+	return image.Rect(int(origin.DstX), int(origin.DstY), int(origin.DstX)+int(geometry.Width), int(origin.DstY)+int(geometry.Height)), nil
+}
+
+// clients returns the top-level windows managed by the window manager in stacking order, bottom first.
+func (f *x11Finder) clients() ([]xproto.Window, error) {
+	for _, name := range []string{"_NET_CLIENT_LIST_STACKING", "_NET_CLIENT_LIST"} {
+		value, err := f.property(f.root, name, xproto.AtomWindow)
+		if err != nil {
+			return nil, err
+		}
+
+		var ids []xproto.Window
+		for i := 0; i+4 <= len(value); i += 4 {
+			ids = append(ids, xproto.Window(xgb.Get32(value[i:])))
+		}
+		if len(ids) > 0 {
+			return ids, nil
+		}
+	}
+
+	return nil, fmt.Errorf("window manager does not list its windows")
+}
+
+// title returns the UTF-8 title of the window, falling back to its legacy name.
+func (f *x11Finder) title(window xproto.Window) string {
+	if value, err := f.property(window, "_NET_WM_NAME", xproto.GetPropertyTypeAny); err == nil && len(value) > 0 {
+		return string(value)
+	}
+	if value, err := f.property(window, "WM_NAME", xproto.GetPropertyTypeAny); err == nil {
+		return string(value)
+	}
+
+	return ""
+}
+
+// class returns the instance and class name of the window.
+func (f *x11Finder) class(window xproto.Window) (instance string, class string) {
+	value, err := f.property(window, "WM_CLASS", xproto.GetPropertyTypeAny)
+	if err != nil {
+		return "", ""
+	}
+
+	names := strings.Split(string(bytes.TrimRight(value, "\x00")), "\x00")
+	instance = names[0]
+	if len(names) > 1 {
+		class = names[1]
+	}
+
+	return instance, class
+}
+
+// process returns the name of the local process owning the window, if the window manager knows it.
+func (f *x11Finder) process(window xproto.Window) string {
+	value, err := f.property(window, "_NET_WM_PID", xproto.AtomCardinal)
+	if err != nil || len(value) < 4 {
+		return ""
+	}
+
+	comm, err := ioutil.ReadFile(fmt.Sprintf("/proc/%d/comm", xgb.Get32(value)))
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(string(comm))
+}
+
+// property returns the value of the named property of the window.
+func (f *x11Finder) property(window xproto.Window, name string, typ xproto.Atom) ([]byte, error) {
+	atom, err := f.atom(name)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := xproto.GetProperty(f.conn, false, window, atom, typ, 0, 1<<16).Reply()
+	if err != nil {
+		return nil, err
+	}
+
+	return reply.Value, nil
+}
+
+// atom returns the interned atom of the name.
+func (f *x11Finder) atom(name string) (xproto.Atom, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if atom, ok := f.atoms[name]; ok {
+		return atom, nil
+	}
+
+	reply, err := xproto.InternAtom(f.conn, false, uint16(len(name)), name).Reply()
+	if err != nil {
+		return 0, err
+	}
+	f.atoms[name] = reply.Atom
+
+	return reply.Atom, nil
+}
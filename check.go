@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// check loads and validates the configuration and prints it resolved.
+// The areas are checked against the active monitors the same way run does, if a display is available.
+func check() error {
+	if err := capture.Reducer(args.Reducer).Verify(); err != nil {
+		return err
+	}
+
+	config, err := ambilight.ReadConfig(args.Config)
+	if err != nil {
+		return err
+	}
+	if len(capture.Monitors()) == 0 {
+		// Without a display, e.g. in CI, only the configuration itself can be checked.
+		config.Warnings = append(config.Warnings, "no display available, the areas are not checked against the monitors")
+	} else if err := capture.VerifyAreas(config.Areas, captureConfig(config, nil)); err != nil {
+		return err
+	}
+
+	return printConfig(os.Stdout, config, args.Screen)
+}
+
+// printConfig prints how the names of the configuration resolve into areas, devices and universes, followed by the warnings.
+func printConfig(out io.Writer, config *ambilight.Configuration, monitor int) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "AREA\tRECTANGLE\tMONITOR\tDEVICES")
+	for _, a := range config.Areas {
+		var devices []string
+		for _, d := range config.Mapping[a] {
+			devices = append(devices, config.DeviceNames[d])
+		}
+		m := capture.Monitor(monitor).String()
+		if c, ok := config.Capture[a]; ok && c.Monitor != nil {
+			m = c.Monitor.String()
+		} else if config.Window != nil {
+			m = "window"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", config.AreaNames[a], a, m, orNone(strings.Join(devices, ", ")))
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "DEVICE\tUNIVERSE\tNET\tSUBNET\tPORT\tCHANNELS")
+	assigned := map[*dmx.Device]bool{}
+	for _, u := range config.Universes {
+		for _, d := range u.Devices {
+			assigned[d] = true
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%d\t%s\n", config.DeviceNames[d], config.UniverseNames[u], u.Net, u.SubNet, uint16(u.Net)<<8|uint16(u.SubNet), channelRanges(d.Channels()))
+		}
+	}
+	var unassigned []string
+	for d, name := range config.DeviceNames {
+		if !assigned[d] {
+			unassigned = append(unassigned, name)
+		}
+	}
+	sort.Strings(unassigned)
+	for _, name := range unassigned {
+		d, _ := config.Device(name)
+		fmt.Fprintf(w, "%s\t-\t-\t-\t-\t%s\n", name, channelRanges(d.Channels()))
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(config.Warnings) > 0 {
+		fmt.Fprintln(out)
+	}
+	for _, warning := range config.Warnings {
+		fmt.Fprintf(out, "warning: %s\n", warning)
+	}
+
+	return nil
+}
+
+// channelRanges returns the sorted 0-based frame channels as consecutive ranges of 1-based DMX channels as set on fixtures, e.g. "1-3,7".
+func channelRanges(channels []uint16) string {
+	var ranges []string
+	for i := 0; i < len(channels); {
+		j := i
+		for j+1 < len(channels) && channels[j+1] <= channels[j]+1 {
+			j++
+		}
+		if channels[i] == channels[j] {
+			ranges = append(ranges, fmt.Sprint(channels[i]+1))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", channels[i]+1, channels[j]+1))
+		}
+		i = j + 1
+	}
+
+	return orNone(strings.Join(ranges, ","))
+}
+
+// orNone returns the text or a dash if the text is empty.
+func orNone(text string) string {
+	if text == "" {
+		return "-"
+	}
+
+	return text
+}
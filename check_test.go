@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"log/slog"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintConfig(t *testing.T) {
+	left := &image.Rectangle{Max: image.Point{X: 10, Y: 30}}
+	right := &image.Rectangle{Min: image.Point{X: 20}, Max: image.Point{X: 30, Y: 30}}
+	par := &dmx.Device{R: 0, G: 1, B: 2, Statics: map[uint16]uint8{5: 255}}
+	spot := &dmx.Device{R: 10, G: 11, B: 12}
+	u := &dmx.Universe{Devices: []*dmx.Device{par}, Net: 1, SubNet: 2}
+	all := capture.MonitorAll
+
+	config := &ambilight.Configuration{
+		Areas:         []*image.Rectangle{left, right},
+		AreaNames:     map[*image.Rectangle]string{left: "left", right: "right"},
+		Universes:     []*dmx.Universe{u},
+		UniverseNames: map[*dmx.Universe]string{u: "u1"},
+		DeviceNames:   map[*dmx.Device]string{par: "par", spot: "spot"},
+		Mapping: ambilight.Mapping{
+			left: []*dmx.Device{par, spot},
+		},
+		Capture: map[*image.Rectangle]capture.AreaConfig{
+			right: capture.AreaConfig{Monitor: &all},
+		},
+		Warnings: []string{
+			"area right is not mapped to any device",
+			"device spot is not part of any universe",
+		},
+	}
+
+	var output bytes.Buffer
+	assert.NoError(t, printConfig(&output, config, 1))
+	assert.Equal(t, ""+
+		"AREA   RECTANGLE       MONITOR  DEVICES\n"+
+		"left   (0,0)-(10,30)   1        par, spot\n"+
+		"right  (20,0)-(30,30)  all      -\n"+
+		"\n"+
+		"DEVICE  UNIVERSE  NET  SUBNET  PORT  CHANNELS\n"+
+		"par     u1        1    2       258   1-3,6\n"+
+		"spot    -         -    -       -     11-13\n"+
+		"\n"+
+		"warning: area right is not mapped to any device\n"+
+		"warning: device spot is not part of any universe\n",
+		output.String())
+}
+
+func TestPrintConfigWindow(t *testing.T) {
+	game := &image.Rectangle{Max: image.Point{X: 10, Y: 30}}
+	desk := &image.Rectangle{Min: image.Point{X: 20}, Max: image.Point{X: 30, Y: 30}}
+	projector := capture.Monitor(1)
+
+	config := &ambilight.Configuration{
+		Areas:     []*image.Rectangle{game, desk},
+		AreaNames: map[*image.Rectangle]string{game: "game", desk: "desk"},
+		Capture: map[*image.Rectangle]capture.AreaConfig{
+			desk: capture.AreaConfig{Monitor: &projector},
+		},
+		Window: &capture.WindowTarget{Class: "vlc"},
+	}
+
+	var output bytes.Buffer
+	assert.NoError(t, printConfig(&output, config, 0))
+	assert.Equal(t, ""+
+		"AREA  RECTANGLE       MONITOR  DEVICES\n"+
+		"game  (0,0)-(10,30)   window   -\n"+
+		"desk  (20,0)-(30,30)  1        -\n"+
+		"\n"+
+		"DEVICE  UNIVERSE  NET  SUBNET  PORT  CHANNELS\n",
+		output.String())
+}
+
+func TestChannelRanges(t *testing.T) {
+	assert.Equal(t, "-", channelRanges(nil))
+	assert.Equal(t, "8", channelRanges([]uint16{7}))
+	assert.Equal(t, "1-3,7-8,10", channelRanges([]uint16{0, 1, 2, 6, 7, 9}))
+	assert.Equal(t, "2-3", channelRanges([]uint16{1, 1, 2}))
+	assert.Equal(t, "512", channelRanges([]uint16{511}))
+}
+
+func TestCheckWithoutDisplay(t *testing.T) {
+	if len(capture.Monitors()) > 0 {
+		t.Skip("a display is available")
+	}
+
+	// The areas cannot be checked against the monitors, which is only a warning.
+	var output bytes.Buffer
+	code := execute([]string{"check", "-config", "config.json"}, &output, func(string) string { return "" }, func(*slog.Logger) {})
+	assert.Equal(t, exitSuccess, code, output.String())
+}
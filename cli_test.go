@@ -0,0 +1,246 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"log/slog"
+	"net"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArguments(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Arguments []string
+
+		ExpectedCommand string
+		ExpectedFlags   map[string]string
+		ExpectedOutput  []string
+		Error           error
+		ErrorText       string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var output bytes.Buffer
+			cmd, flags, err := parseArguments(tc.Arguments, &output)
+
+			for _, expected := range tc.ExpectedOutput {
+				assert.Contains(t, output.String(), expected)
+			}
+			if tc.Error != nil {
+				assert.Equal(t, tc.Error, err)
+
+				return
+			} else if tc.ErrorText != "" {
+				assert.EqualError(t, err, tc.ErrorText)
+
+				return
+			}
+			assert.NoError(t, err)
+
+			assert.Equal(t, tc.ExpectedCommand, cmd.Name)
+			for name, expected := range tc.ExpectedFlags {
+				assert.Equal(t, expected, flags.Lookup(name).Value.String(), name)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Run",
+
+		Arguments: []string{"run", "-dst", "2.0.0.1", "-config", "living-room.yaml", "-pause", "20"},
+
+		ExpectedCommand: "run",
+		ExpectedFlags: map[string]string{
+			"dst":     "2.0.0.1",
+			"config":  "living-room.yaml",
+			"pause":   "20",
+			"spacing": "1",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Defaults",
+
+		Arguments: []string{"discover"},
+
+		ExpectedCommand: "discover",
+		ExpectedFlags: map[string]string{
+			"timeout":   "3000",
+			"log-level": "info",
+		},
+	})
+	validate(t, &testCase{
+		Name: "No Command",
+
+		ExpectedOutput: []string{"usage: screentoartnet <command> [flags]", "  discover  list the ArtNet nodes answering a poll"},
+		Error:          errUnknownCommand,
+	})
+	validate(t, &testCase{
+		Name: "Unknown Command",
+
+		Arguments: []string{"-mode", "run"},
+
+		ExpectedOutput: []string{"unknown command: -mode", "usage: screentoartnet <command> [flags]"},
+		Error:          errUnknownCommand,
+	})
+	validate(t, &testCase{
+		Name: "Help",
+
+		Arguments: []string{"help"},
+
+		ExpectedOutput: []string{"  version   print the version"},
+		Error:          flag.ErrHelp,
+	})
+	validate(t, &testCase{
+		Name: "Command Help",
+
+		Arguments: []string{"help", "preview"},
+
+		ExpectedOutput: []string{"usage: screentoartnet preview [flags]", "-screen int"},
+		Error:          flag.ErrHelp,
+	})
+	validate(t, &testCase{
+		Name: "Command Help Flag",
+
+		Arguments: []string{"check", "-h"},
+
+		ExpectedOutput: []string{"usage: screentoartnet check [flags]", "-config string"},
+		Error:          flag.ErrHelp,
+	})
+	validate(t, &testCase{
+		Name: "Flag Of Other Command",
+
+		Arguments: []string{"preview", "-dst", "2.0.0.1"},
+
+		ExpectedOutput: []string{"flag provided but not defined: -dst", "usage: screentoartnet preview [flags]"},
+		ErrorText:      "flag provided but not defined: -dst",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Flag Value",
+
+		Arguments: []string{"run", "-pause", "soon"},
+
+		ErrorText: `invalid value "soon" for flag -pause: parse error`,
+	})
+	validate(t, &testCase{
+		Name: "Unexpected Arguments",
+
+		Arguments: []string{"check", "config.json"},
+
+		ExpectedOutput: []string{"unexpected arguments: config.json", "usage: screentoartnet check [flags]"},
+		ErrorText:      "unexpected arguments: config.json",
+	})
+}
+
+func TestExecuteUsage(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Arguments []string
+
+		ExpectedCode   int
+		ExpectedOutput string
+		ExpectedSetup  bool
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var output bytes.Buffer
+			setup := false
+			code := execute(tc.Arguments, &output, func(string) string { return "" }, func(*slog.Logger) { setup = true })
+
+			assert.Equal(t, tc.ExpectedCode, code)
+			assert.Contains(t, output.String(), tc.ExpectedOutput)
+			assert.Equal(t, tc.ExpectedSetup, setup)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "No Command",
+
+		ExpectedCode:   exitUsage,
+		ExpectedOutput: "usage: screentoartnet <command> [flags]",
+	})
+	validate(t, &testCase{
+		Name: "Unknown Command",
+
+		Arguments: []string{"unknown"},
+
+		ExpectedCode:   exitUsage,
+		ExpectedOutput: "unknown command: unknown",
+	})
+	validate(t, &testCase{
+		Name: "Help",
+
+		Arguments: []string{"help"},
+
+		ExpectedCode:   exitSuccess,
+		ExpectedOutput: "usage: screentoartnet <command> [flags]",
+	})
+	validate(t, &testCase{
+		Name: "Invalid Log Level",
+
+		Arguments: []string{"version", "-log-level", "verbose"},
+
+		ExpectedCode:   exitUsage,
+		ExpectedOutput: "verbose",
+	})
+	validate(t, &testCase{
+		Name: "Version",
+
+		Arguments: []string{"version"},
+
+		ExpectedCode:  exitSuccess,
+		ExpectedSetup: true,
+	})
+	validate(t, &testCase{
+		Name: "Failure",
+
+		Arguments: []string{"check", "-config", "missing.json"},
+
+		ExpectedCode:   exitFailure,
+		ExpectedOutput: "invalid configuration: ",
+	})
+}
+
+func TestVersionString(t *testing.T) {
+	defer func(v string) { version = v }(version)
+
+	version = "v1.2.3"
+	assert.Contains(t, versionString(), "screentoartnet v1.2.3 ")
+}
+
+func TestPrintMonitors(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, printMonitors(&output, []image.Rectangle{image.Rect(0, 0, 1920, 1080), image.Rect(1920, 0, 4480, 1440)}))
+	assert.Equal(t, "monitor 0: 1920x1080 at (0,0)\nmonitor 1: 2560x1440 at (1920,0)\n", output.String())
+
+	assert.EqualError(t, printMonitors(&output, nil), "no monitors found")
+}
+
+func TestPrintNodes(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, printNodes(&output, []*dmx.Node{
+		&dmx.Node{
+			IP:        net.IP{2, 0, 0, 1},
+			ShortName: "node",
+			LongName:  "ArtNet node",
+			SubNet:    1,
+			Outputs:   []uint8{0, 1},
+		},
+	}))
+	assert.Equal(t, ""+
+		"IP       NAME  DESCRIPTION  NET  SUBNET  OUTPUTS\n"+
+		"2.0.0.1  node  ArtNet node  0    1       0,1\n",
+		output.String())
+
+	output.Reset()
+	assert.NoError(t, printNodes(&output, nil))
+	assert.Equal(t, "no ArtNet nodes found\n", output.String())
+}
@@ -0,0 +1,217 @@
+package correction
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+)
+
+// Color holds an RGB color with channel values between 0 and 1.
+type Color struct {
+	// R holds the red channel.
+	R float64
+	// G holds the green channel.
+	G float64
+	// B holds the blue channel.
+	B float64
+}
+
+// FromRGBA64 converts the given 16-bit color.
+func FromRGBA64(c color.RGBA64) Color {
+	return Color{
+		R: float64(c.R) / math.MaxUint16,
+		G: float64(c.G) / math.MaxUint16,
+		B: float64(c.B) / math.MaxUint16,
+	}
+}
+
+// RGBA64 converts the color to a 16-bit color, clamping the channels.
+func (c Color) RGBA64() color.RGBA64 {
+	return color.RGBA64{
+		R: uint16(math.Round(clamp(c.R) * math.MaxUint16)),
+		G: uint16(math.Round(clamp(c.G) * math.MaxUint16)),
+		B: uint16(math.Round(clamp(c.B) * math.MaxUint16)),
+		A: math.MaxUint16,
+	}
+}
+
+// Transform transforms a color.
+type Transform interface {
+	// Apply returns the transformed color.
+	Apply(c Color) Color
+}
+
+// Chain applies its transforms one after another.
+type Chain []Transform
+
+// Apply returns the color transformed by all transforms of the chain.
+func (ch Chain) Apply(c Color) Color {
+	for _, t := range ch {
+		c = t.Apply(c)
+	}
+
+	return c
+}
+
+// Gamma applies an output gamma curve.
+type Gamma float64
+
+// Apply returns the gamma corrected color.
+func (g Gamma) Apply(c Color) Color {
+	return Color{
+		R: math.Pow(clamp(c.R), float64(g)),
+		G: math.Pow(clamp(c.G), float64(g)),
+		B: math.Pow(clamp(c.B), float64(g)),
+	}
+}
+
+// Gain scales every channel individually, e.g. to balance the white point.
+type Gain [3]float64
+
+// Apply returns the scaled color.
+func (g Gain) Apply(c Color) Color {
+	return Color{
+		R: c.R * g[0],
+		G: c.G * g[1],
+		B: c.B * g[2],
+	}
+}
+
+// Saturation scales the distance of the color to its gray value.
+type Saturation float64
+
+// Apply returns the saturated color.
+func (s Saturation) Apply(c Color) Color {
+	gray := luma(c)
+
+	return Color{
+		R: gray + (c.R-gray)*float64(s),
+		G: gray + (c.G-gray)*float64(s),
+		B: gray + (c.B-gray)*float64(s),
+	}
+}
+
+// Brightness scales the color so that its brightest channel lies within the given range.
+type Brightness struct {
+	// Min holds the minimum brightness.
+	Min float64
+	// Max holds the maximum brightness.
+	Max float64
+}
+
+// Apply returns the clamped color.
+func (b Brightness) Apply(c Color) Color {
+	brightness := math.Max(c.R, math.Max(c.G, c.B))
+
+	switch {
+	case brightness <= 0:
+		return Color{R: b.Min, G: b.Min, B: b.Min}
+	case brightness < b.Min:
+		return scale(c, b.Min/brightness)
+	case brightness > b.Max:
+		return scale(c, b.Max/brightness)
+	}
+
+	return c
+}
+
+// Matrix multiplies the color, as a column vector, with a 3x3 matrix.
+type Matrix [3][3]float64
+
+// Apply returns the multiplied color.
+func (m Matrix) Apply(c Color) Color {
+	return Color{
+		R: m[0][0]*c.R + m[0][1]*c.G + m[0][2]*c.B,
+		G: m[1][0]*c.R + m[1][1]*c.G + m[1][2]*c.B,
+		B: m[2][0]*c.R + m[2][1]*c.G + m[2][2]*c.B,
+	}
+}
+
+// Config holds the color correction configuration of a device.
+type Config struct {
+	// Matrix holds an optional color matrix.
+	Matrix *Matrix `json:",omitempty"`
+	// WhiteBalance holds optional gains for the red, green and blue channel.
+	WhiteBalance *Gain `json:",omitempty"`
+	// Saturation holds the saturation factor, 0 disables the saturation adjustment.
+	Saturation float64
+	// MinBrightness holds the minimum brightness between 0 and 1.
+	MinBrightness float64
+	// MaxBrightness holds the maximum brightness between 0 and 1, 0 disables the brightness clamp.
+	MaxBrightness float64
+	// Gamma holds the output gamma, 0 disables the gamma curve.
+	Gamma float64
+}
+
+// Verify checks if the Config is a valid color correction.
+func (c *Config) Verify() error {
+	if c.WhiteBalance != nil {
+		for _, g := range c.WhiteBalance {
+			if g < 0 {
+				return fmt.Errorf("invalid white balance gain (%v)", g)
+			}
+		}
+	}
+	if c.Saturation < 0 {
+		return fmt.Errorf("invalid saturation (%v)", c.Saturation)
+	}
+	if c.MinBrightness < 0 || c.MinBrightness > 1 {
+		return fmt.Errorf("invalid minimum brightness (%v)", c.MinBrightness)
+	}
+	if c.MaxBrightness < 0 || c.MaxBrightness > 1 {
+		return fmt.Errorf("invalid maximum brightness (%v)", c.MaxBrightness)
+	}
+	if c.MaxBrightness > 0 && c.MinBrightness > c.MaxBrightness {
+		return fmt.Errorf("minimum brightness exceeds maximum brightness (min=%v, max=%v)", c.MinBrightness, c.MaxBrightness)
+	}
+	if c.Gamma < 0 {
+		return fmt.Errorf("invalid gamma (%v)", c.Gamma)
+	}
+
+	return nil
+}
+
+// Chain returns the transform chain of the configuration.
+// The transforms are applied in the order matrix, white balance, saturation, brightness and gamma.
+func (c *Config) Chain() Chain {
+	var chain Chain
+
+	if c.Matrix != nil {
+		chain = append(chain, *c.Matrix)
+	}
+	if c.WhiteBalance != nil {
+		chain = append(chain, *c.WhiteBalance)
+	}
+	if c.Saturation > 0 {
+		chain = append(chain, Saturation(c.Saturation))
+	}
+	if c.MinBrightness > 0 || c.MaxBrightness > 0 {
+		max := c.MaxBrightness
+		if max == 0 {
+			max = 1
+		}
+		chain = append(chain, Brightness{Min: c.MinBrightness, Max: max})
+	}
+	if c.Gamma > 0 {
+		chain = append(chain, Gamma(c.Gamma))
+	}
+
+	return chain
+}
+
+// luma returns the Rec. 709 luma of the color.
+func luma(c Color) float64 {
+	return 0.2126*c.R + 0.7152*c.G + 0.0722*c.B
+}
+
+func scale(c Color, factor float64) Color {
+	return Color{
+		R: c.R * factor,
+		G: c.G * factor,
+		B: c.B * factor,
+	}
+}
+
+func clamp(v float64) float64 {
+	return math.Max(0, math.Min(1, v))
+}
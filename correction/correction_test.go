@@ -0,0 +1,243 @@
+package correction
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func assertColorInDelta(t *testing.T, expected Color, actual Color) {
+	assert.InDelta(t, expected.R, actual.R, 1e-9, "red")
+	assert.InDelta(t, expected.G, actual.G, 1e-9, "green")
+	assert.InDelta(t, expected.B, actual.B, 1e-9, "blue")
+}
+
+func TestColorConversion(t *testing.T) {
+	c := FromRGBA64(color.RGBA64{R: 0xffff, G: 0x3333, B: 0, A: 0xffff})
+	assertColorInDelta(t, Color{R: 1, G: 0.2, B: 0}, c)
+
+	assert.Equal(t, color.RGBA64{R: 0xffff, G: 0x8000, B: 0, A: 0xffff}, Color{R: 2, G: 0.5, B: -1}.RGBA64())
+}
+
+func TestTransforms(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Transform Transform
+		Input     Color
+		Expected  Color
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assertColorInDelta(t, tc.Expected, tc.Transform.Apply(tc.Input))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Gamma",
+
+		Transform: Gamma(2),
+		Input:     Color{R: 0.5, G: 1, B: 0},
+		Expected:  Color{R: 0.25, G: 1, B: 0},
+	})
+	validate(t, &testCase{
+		Name: "Gain",
+
+		Transform: Gain{1, 0.5, 0.25},
+		Input:     Color{R: 1, G: 1, B: 1},
+		Expected:  Color{R: 1, G: 0.5, B: 0.25},
+	})
+	t.Run("Saturation", func(t *testing.T) {
+		validate(t, &testCase{
+			Name: "Gray",
+
+			Transform: Saturation(2),
+			Input:     Color{R: 0.5, G: 0.5, B: 0.5},
+			Expected:  Color{R: 0.5, G: 0.5, B: 0.5},
+		})
+		validate(t, &testCase{
+			Name: "Desaturate",
+
+			Transform: Saturation(0),
+			Input:     Color{R: 1, G: 0, B: 0},
+			Expected:  Color{R: 0.2126, G: 0.2126, B: 0.2126},
+		})
+		validate(t, &testCase{
+			Name: "Boost",
+
+			Transform: Saturation(2),
+			Input:     Color{R: 0.6, G: 0.4, B: 0.4},
+			Expected:  Color{R: 0.75748, G: 0.35748, B: 0.35748},
+		})
+	})
+	t.Run("Brightness", func(t *testing.T) {
+		validate(t, &testCase{
+			Name: "Black",
+
+			Transform: Brightness{Min: 0.1, Max: 1},
+			Input:     Color{},
+			Expected:  Color{R: 0.1, G: 0.1, B: 0.1},
+		})
+		validate(t, &testCase{
+			Name: "Minimum",
+
+			Transform: Brightness{Min: 0.1, Max: 1},
+			Input:     Color{R: 0.05, G: 0.025},
+			Expected:  Color{R: 0.1, G: 0.05},
+		})
+		validate(t, &testCase{
+			Name: "Maximum",
+
+			Transform: Brightness{Min: 0, Max: 0.5},
+			Input:     Color{R: 1, G: 0.5},
+			Expected:  Color{R: 0.5, G: 0.25},
+		})
+		validate(t, &testCase{
+			Name: "Within Range",
+
+			Transform: Brightness{Min: 0.1, Max: 0.9},
+			Input:     Color{R: 0.5, G: 0.2, B: 0.1},
+			Expected:  Color{R: 0.5, G: 0.2, B: 0.1},
+		})
+	})
+	validate(t, &testCase{
+		Name: "Matrix",
+
+		Transform: Matrix{
+			{0, 1, 0},
+			{0, 0, 1},
+			{1, 0, 0},
+		},
+		Input:    Color{R: 0.1, G: 0.2, B: 0.3},
+		Expected: Color{R: 0.2, G: 0.3, B: 0.1},
+	})
+	validate(t, &testCase{
+		Name: "Chain",
+
+		Transform: Chain{
+			Gain{0.5, 1, 1},
+			Gamma(2),
+		},
+		Input:    Color{R: 1, G: 0.5, B: 1},
+		Expected: Color{R: 0.25, G: 0.25, B: 1},
+	})
+}
+
+func TestConfigVerify(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Config *Config
+		Error  error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Config.Verify()
+			assert.Equal(t, tc.Error, err)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Config: &Config{
+			WhiteBalance:  &Gain{1, 0.9, 0.8},
+			Saturation:    1.2,
+			MinBrightness: 0.1,
+			MaxBrightness: 0.9,
+			Gamma:         2.2,
+		},
+	})
+	validate(t, &testCase{
+		Name: "White Balance",
+
+		Config: &Config{
+			WhiteBalance: &Gain{1, -1, 1},
+		},
+		Error: errors.New("invalid white balance gain (-1)"),
+	})
+	validate(t, &testCase{
+		Name: "Saturation",
+
+		Config: &Config{
+			Saturation: -1,
+		},
+		Error: errors.New("invalid saturation (-1)"),
+	})
+	validate(t, &testCase{
+		Name: "Minimum Brightness",
+
+		Config: &Config{
+			MinBrightness: 2,
+		},
+		Error: errors.New("invalid minimum brightness (2)"),
+	})
+	validate(t, &testCase{
+		Name: "Maximum Brightness",
+
+		Config: &Config{
+			MaxBrightness: -1,
+		},
+		Error: errors.New("invalid maximum brightness (-1)"),
+	})
+	validate(t, &testCase{
+		Name: "Brightness Range",
+
+		Config: &Config{
+			MinBrightness: 0.5,
+			MaxBrightness: 0.4,
+		},
+		Error: errors.New("minimum brightness exceeds maximum brightness (min=0.5, max=0.4)"),
+	})
+	validate(t, &testCase{
+		Name: "Gamma",
+
+		Config: &Config{
+			Gamma: -1,
+		},
+		Error: errors.New("invalid gamma (-1)"),
+	})
+}
+
+func TestConfigChain(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Config   *Config
+		Expected Chain
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Config.Chain())
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Empty",
+
+		Config: &Config{},
+	})
+	validate(t, &testCase{
+		Name: "Complete",
+
+		Config: &Config{
+			Matrix:        &Matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+			WhiteBalance:  &Gain{1, 0.9, 0.8},
+			Saturation:    1.2,
+			MinBrightness: 0.1,
+			Gamma:         2.2,
+		},
+		Expected: Chain{
+			Matrix{{1, 0, 0}, {0, 1, 0}, {0, 0, 1}},
+			Gain{1, 0.9, 0.8},
+			Saturation(1.2),
+			Brightness{Min: 0.1, Max: 1},
+			Gamma(2.2),
+		},
+	})
+}
@@ -2,6 +2,7 @@ package dmx
 
 import (
 	"fmt"
+	"log/slog"
 	"net"
 
 	"github.com/jsimonetti/go-artnet/packet"
@@ -40,7 +41,12 @@ func (c *ArtNetController) SendDMX(frame DMXFrame, net uint8, sub uint8) error {
 }
 
 // NewArtNetController registers all ArtNet communication on this device to control the given ArtNet target node.
-func NewArtNetController(srcIP string, dstIP string) (*ArtNetController, error) {
+// A nil logger logs to the default logger.
+func NewArtNetController(srcIP string, dstIP string, logger *slog.Logger) (*ArtNetController, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
 	nodeAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", dstIP, packet.ArtNetPort))
 	if err != nil {
 		return nil, err
@@ -56,6 +62,8 @@ func NewArtNetController(srcIP string, dstIP string) (*ArtNetController, error)
 		return nil, err
 	}
 
+	logger.Info("ArtNet node selected", "node", nodeAddr.String(), "local", conn.LocalAddr().String())
+
 	return &ArtNetController{
 		node: nodeAddr,
 		gate: conn,
@@ -0,0 +1,125 @@
+package dmx
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/jsimonetti/go-artnet/packet"
+)
+
+// Node holds an ArtNet node that answered a poll.
+type Node struct {
+	// IP holds the IP address of the node.
+	IP net.IP
+	// BindIndex holds the index of the answering port group for nodes with more than four ports, otherwise 0.
+	BindIndex uint8
+	// ShortName holds the short name of the node.
+	ShortName string
+	// LongName holds the long name of the node.
+	LongName string
+
+	// Net holds the ArtNet net of the outputs.
+	Net uint8
+	// SubNet holds the ArtNet sub-net of the outputs.
+	SubNet uint8
+	// Outputs holds the universes of the outputs within the sub-net.
+	Outputs []uint8
+}
+
+// Discover broadcasts an ArtNet poll from the given source IP and returns the nodes answering within the timeout, ordered by their IP addresses.
+func Discover(srcIP string, timeout time.Duration) ([]*Node, error) {
+	localAddr, err := net.ResolveUDPAddr("udp", fmt.Sprintf("%s:%d", srcIP, packet.ArtNetPort))
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := net.ListenUDP("udp", localAddr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	return discover(conn, &net.UDPAddr{IP: net.IPv4bcast, Port: packet.ArtNetPort}, timeout)
+}
+
+// discover sends an ArtNet poll over the connection to the given address and collects the replies until the timeout.
+func discover(conn net.PacketConn, broadcast net.Addr, timeout time.Duration) ([]*Node, error) {
+	poll, err := packet.NewArtPollPacket().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo(poll, broadcast); err != nil {
+		return nil, err
+	}
+
+	if err := conn.SetReadDeadline(time.Now().Add(timeout)); err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	var nodes []*Node
+	buffer := make([]byte, 1024)
+	for {
+		n, _, err := conn.ReadFrom(buffer)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				break
+			}
+
+			return nil, err
+		}
+
+		// Other packets, e.g. our own broadcast poll, are ignored.
+		p, err := packet.Unmarshal(buffer[:n])
+		if err != nil {
+			continue
+		}
+		reply, ok := p.(*packet.ArtPollReplyPacket)
+		if !ok {
+			continue
+		}
+
+		node := newNode(reply)
+		key := fmt.Sprintf("%s/%d", node.IP, node.BindIndex)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		nodes = append(nodes, node)
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		if c := bytes.Compare(nodes[i].IP, nodes[j].IP); c != 0 {
+			return c < 0
+		}
+
+		return nodes[i].BindIndex < nodes[j].BindIndex
+	})
+
+	return nodes, nil
+}
+
+// newNode returns the node of the poll reply.
+func newNode(reply *packet.ArtPollReplyPacket) *Node {
+	node := &Node{
+		IP:        net.IPv4(reply.IPAddress[0], reply.IPAddress[1], reply.IPAddress[2], reply.IPAddress[3]).To4(),
+		BindIndex: reply.BindIndex,
+		ShortName: string(bytes.TrimRight(reply.ShortName[:], "\x00")),
+		LongName:  string(bytes.TrimRight(reply.LongName[:], "\x00")),
+		Net:       reply.NetSwitch,
+		SubNet:    reply.SubSwitch,
+	}
+
+	ports := int(reply.NumPorts)
+	if ports > len(reply.SwOut) {
+		ports = len(reply.SwOut)
+	}
+	for i := 0; i < ports; i++ {
+		node.Outputs = append(node.Outputs, reply.SwOut[i])
+	}
+
+	return node
+}
@@ -0,0 +1,77 @@
+package dmx
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jsimonetti/go-artnet/packet"
+	"github.com/jsimonetti/go-artnet/packet/code"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiscover(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer conn.Close()
+	node, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer node.Close()
+
+	// The fake node answers a poll twice with every port group.
+	go func() {
+		buffer := make([]byte, 1024)
+		n, addr, err := node.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		if p, err := packet.Unmarshal(buffer[:n]); err != nil || p.GetOpCode() != code.OpPoll {
+			return
+		}
+
+		for _, bindIndex := range []uint8{2, 1, 2} {
+			reply := &packet.ArtPollReplyPacket{
+				IPAddress: [4]byte{2, 0, 0, 1},
+				Port:      packet.ArtNetPort,
+				NetSwitch: 1,
+				SubSwitch: 2,
+				NumPorts:  2,
+				SwOut:     [4]uint8{3, 4},
+				BindIndex: bindIndex,
+				Style:     code.StNode,
+			}
+			copy(reply.ShortName[:], "node")
+			copy(reply.LongName[:], "ArtNet node")
+			data, err := reply.MarshalBinary()
+			if err != nil {
+				return
+			}
+			node.WriteTo(data, addr)
+		}
+		node.WriteTo([]byte("garbage"), addr)
+	}()
+
+	nodes, err := discover(conn, node.LocalAddr(), 200*time.Millisecond)
+	assert.NoError(t, err)
+	assert.Equal(t, []*Node{
+		&Node{
+			IP:        net.IP{2, 0, 0, 1},
+			BindIndex: 1,
+			ShortName: "node",
+			LongName:  "ArtNet node",
+			Net:       1,
+			SubNet:    2,
+			Outputs:   []uint8{3, 4},
+		},
+		&Node{
+			IP:        net.IP{2, 0, 0, 1},
+			BindIndex: 2,
+			ShortName: "node",
+			LongName:  "ArtNet node",
+			Net:       1,
+			SubNet:    2,
+			Outputs:   []uint8{3, 4},
+		},
+	}, nodes)
+}
@@ -2,6 +2,30 @@ package dmx
 
 import (
 	"fmt"
+	"math"
+	"sort"
+)
+
+// Role identifies the function of a device channel.
+type Role string
+
+const (
+	// Red identifies a red emitter channel.
+	Red Role = "red"
+	// Green identifies a green emitter channel.
+	Green Role = "green"
+	// Blue identifies a blue emitter channel.
+	Blue Role = "blue"
+	// White identifies a white emitter channel.
+	White Role = "white"
+	// Amber identifies an amber emitter channel.
+	Amber Role = "amber"
+	// UV identifies an ultraviolet emitter channel.
+	UV Role = "uv"
+	// ColdWhite identifies a cold white emitter channel.
+	ColdWhite Role = "cold white"
+	// WarmWhite identifies a warm white emitter channel.
+	WarmWhite Role = "warm white"
 )
 
 // Device holds the DMX data of an rgb device.
@@ -13,15 +37,64 @@ type Device struct {
 	// B holds the blue channel.
 	B uint16 `json:"Blue"`
 
-	// RValue holds the red value.
-	RValue uint8
-	// RValue holds the green value.
-	GValue uint8
-	// RValue holds the blue value.
-	BValue uint8
+	// W holds the optional white channel.
+	W *uint16 `json:"White,omitempty"`
+	// A holds the optional amber channel.
+	A *uint16 `json:"Amber,omitempty"`
+	// UV holds the optional ultraviolet channel.
+	UV *uint16 `json:"UV,omitempty"`
+	// CW holds the optional cold white channel.
+	CW *uint16 `json:"ColdWhite,omitempty"`
+	// WW holds the optional warm white channel.
+	WW *uint16 `json:"WarmWhite,omitempty"`
+
+	// RFine holds the optional fine red channel.
+	RFine *uint16 `json:"RedFine,omitempty"`
+	// GFine holds the optional fine green channel.
+	GFine *uint16 `json:"GreenFine,omitempty"`
+	// BFine holds the optional fine blue channel.
+	BFine *uint16 `json:"BlueFine,omitempty"`
+	// WFine holds the optional fine white channel.
+	WFine *uint16 `json:"WhiteFine,omitempty"`
+	// AFine holds the optional fine amber channel.
+	AFine *uint16 `json:"AmberFine,omitempty"`
+	// UVFine holds the optional fine ultraviolet channel.
+	UVFine *uint16 `json:"UVFine,omitempty"`
+	// CWFine holds the optional fine cold white channel.
+	CWFine *uint16 `json:"ColdWhiteFine,omitempty"`
+	// WWFine holds the optional fine warm white channel.
+	WWFine *uint16 `json:"WarmWhiteFine,omitempty"`
+
+	// Extraction holds the algorithm deriving the additional emitters from the color.
+	Extraction Extraction `json:",omitempty"`
+
+	// RValue holds the 16-bit red value.
+	RValue uint16 `json:"-"`
+	// RValue holds the 16-bit green value.
+	GValue uint16 `json:"-"`
+	// RValue holds the 16-bit blue value.
+	BValue uint16 `json:"-"`
+
+	// WValue holds the 16-bit white value.
+	WValue uint16 `json:"-"`
+	// AValue holds the 16-bit amber value.
+	AValue uint16 `json:"-"`
+	// UVValue holds the 16-bit ultraviolet value.
+	UVValue uint16 `json:"-"`
+	// CWValue holds the 16-bit cold white value.
+	CWValue uint16 `json:"-"`
+	// WWValue holds the 16-bit warm white value.
+	WWValue uint16 `json:"-"`
 
 	// Statics holds the static DMX data for this device
-	Statics map[uint16]uint8
+	Statics map[uint16]uint8 `json:",omitempty"`
+	// Drivers holds the channels driven by the measures of the screen area of this device.
+	Drivers map[uint16]*Driver `json:",omitempty"`
+	// MasterExclude holds the color channels of this device that are not scaled by the master intensity, listing the coarse or the fine channel of a color.
+	// Statics and drivers are only scaled if listed in MasterInclude.
+	MasterExclude []uint16 `json:",omitempty"`
+	// MasterInclude holds the static and driven channels of this device that are scaled by the master intensity, e.g. a dimmer following the master.
+	MasterInclude []uint16 `json:",omitempty"`
 }
 
 // Verify checks if the Device is a valid DMX device.
@@ -40,22 +113,192 @@ func (d *Device) Verify() error {
 		return fmt.Errorf("color channels should be different (r=%v, g=%v, b=%v)", d.R, d.G, d.B)
 	}
 
+	roles := map[uint16]string{}
+	use := func(name string, channel uint16) error {
+		if channel > 511 {
+			return fmt.Errorf("%s channel outside of DMX range (channel=%v)", name, channel)
+		}
+		if other, ok := roles[channel]; ok {
+			return fmt.Errorf("%s channel collides with %s channel (channel=%v)", name, other, channel)
+		}
+		roles[channel] = name
+
+		return nil
+	}
+	for _, c := range d.colorChannels() {
+		if err := use(string(c.role), *c.coarse); err != nil {
+			return err
+		}
+		if c.fine != nil {
+			if err := use(string(c.role)+" fine", *c.fine); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := d.Extraction.Verify(); err != nil {
+		return err
+	}
+
 	for channel := range d.Statics {
 		if channel > 511 {
 			return fmt.Errorf("invalid static channel outside of DMX range (channel=%v)", channel)
 		}
 	}
 
+	for channel, driver := range d.Drivers {
+		if err := use("driver", channel); err != nil {
+			return err
+		}
+		if _, ok := d.Statics[channel]; ok {
+			return fmt.Errorf("driver channel collides with static channel (channel=%v)", channel)
+		}
+		if err := driver.Verify(); err != nil {
+			return err
+		}
+	}
+
+	// Statics and drivers are not scaled anyway, so only color channels can be excluded.
+	colors := map[uint16]bool{}
+	for _, c := range d.colorChannels() {
+		colors[*c.coarse] = true
+		if c.fine != nil {
+			colors[*c.fine] = true
+		}
+	}
+	for _, channel := range d.MasterExclude {
+		if !colors[channel] {
+			return fmt.Errorf("master excluded channel is not a color channel of the device (channel=%v)", channel)
+		}
+	}
+
+	for _, channel := range d.MasterInclude {
+		if _, ok := d.Statics[channel]; ok {
+			continue
+		}
+		if _, ok := d.Drivers[channel]; ok {
+			continue
+		}
+
+		return fmt.Errorf("master included channel is not a static or driven channel of the device (channel=%v)", channel)
+	}
+
 	return nil
 }
 
-// UpdateFrame updates the given DMX frame with the current channel values.
-func (d *Device) UpdateFrame(frame *DMXFrame) {
-	frame[d.R] = d.RValue
-	frame[d.G] = d.GValue
-	frame[d.B] = d.BValue
+// UpdateDrivers updates the driven channels with the given measures of the screen area.
+func (d *Device) UpdateDrivers(m Measures) {
+	for _, driver := range d.Drivers {
+		driver.Update(m)
+	}
+}
+
+// UpdateFrame updates the given DMX frame with the current channel values scaled by the master intensity between 0 and 1.
+// Statics and drivers keep their values unless they are included in the master.
+// Color values are written to the coarse channel and, if equipped, with their lower 8 bits to the fine channel, otherwise they are rounded to 8 bits.
+func (d *Device) UpdateFrame(frame *DMXFrame, master float64) {
+	excluded := make(map[uint16]bool, len(d.MasterExclude))
+	for _, channel := range d.MasterExclude {
+		excluded[channel] = true
+	}
+	included := make(map[uint16]bool, len(d.MasterInclude))
+	for _, channel := range d.MasterInclude {
+		included[channel] = true
+	}
+
+	for _, c := range d.colorChannels() {
+		// Scale in 16-bit so the fine channel keeps the precision.
+		v := *c.value
+		if !excluded[*c.coarse] && (c.fine == nil || !excluded[*c.fine]) {
+			v = uint16(math.Round(float64(v) * master))
+		}
+
+		if c.fine == nil {
+			frame[*c.coarse] = round8(v)
+
+			continue
+		}
+		frame[*c.coarse] = uint8(v >> 8)
+		frame[*c.fine] = uint8(v)
+	}
 
 	for channel, value := range d.Statics {
-		frame[channel] = value
+		frame[channel] = scale(value, master, !included[channel])
+	}
+
+	for channel, driver := range d.Drivers {
+		frame[channel] = scale(driver.Value, master, !included[channel])
+	}
+}
+
+// round8 returns the 16-bit value rounded to 8 bits.
+func round8(v uint16) uint8 {
+	if v >= 0xff80 {
+		return 0xff
+	}
+
+	return uint8((v + 0x80) >> 8)
+}
+
+// scale returns the value scaled by the master intensity unless excluded.
+func scale(value uint8, master float64, excluded bool) uint8 {
+	if excluded {
+		return value
+	}
+
+	return uint8(math.Round(float64(value) * master))
+}
+
+// Channels returns all channels used by the device in ascending order.
+func (d *Device) Channels() []uint16 {
+	var channels []uint16
+
+	for _, c := range d.colorChannels() {
+		channels = append(channels, *c.coarse)
+		if c.fine != nil {
+			channels = append(channels, *c.fine)
+		}
+	}
+	for channel := range d.Statics {
+		channels = append(channels, channel)
+	}
+	for channel := range d.Drivers {
+		channels = append(channels, channel)
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		return channels[i] < channels[j]
+	})
+
+	return channels
+}
+
+// colorChannel holds a color channel of a device.
+type colorChannel struct {
+	role   Role
+	coarse *uint16
+	fine   *uint16
+	value  *uint16
+}
+
+// colorChannels returns the color channels the device is equipped with.
+func (d *Device) colorChannels() []colorChannel {
+	var channels []colorChannel
+
+	for _, c := range []colorChannel{
+		{role: Red, coarse: &d.R, fine: d.RFine, value: &d.RValue},
+		{role: Green, coarse: &d.G, fine: d.GFine, value: &d.GValue},
+		{role: Blue, coarse: &d.B, fine: d.BFine, value: &d.BValue},
+		{role: White, coarse: d.W, fine: d.WFine, value: &d.WValue},
+		{role: Amber, coarse: d.A, fine: d.AFine, value: &d.AValue},
+		{role: UV, coarse: d.UV, fine: d.UVFine, value: &d.UVValue},
+		{role: ColdWhite, coarse: d.CW, fine: d.CWFine, value: &d.CWValue},
+		{role: WarmWhite, coarse: d.WW, fine: d.WWFine, value: &d.WWValue},
+	} {
+		if c.coarse != nil {
+			channels = append(channels, c)
+		}
 	}
+
+	return channels
 }
@@ -64,6 +64,96 @@ func TestDeviceVerify(t *testing.T) {
 			Error: errors.New("color channels should be different (r=0, g=0, b=0)"),
 		})
 	})
+	t.Run("Emitter Channels", func(t *testing.T) {
+		channel := func(c uint16) *uint16 {
+			return &c
+		}
+
+		validate(t, &testCase{
+			Name: "Valid",
+
+			Device: &Device{
+				R:  1,
+				G:  2,
+				B:  3,
+				W:  channel(4),
+				A:  channel(5),
+				UV: channel(6),
+				CW: channel(7),
+				WW: channel(8),
+
+				RFine:  channel(9),
+				GFine:  channel(10),
+				BFine:  channel(11),
+				WFine:  channel(12),
+				AFine:  channel(13),
+				UVFine: channel(14),
+				CWFine: channel(15),
+				WWFine: channel(16),
+
+				Extraction: ExtractionSubtractive,
+			},
+		})
+		validate(t, &testCase{
+			Name: "Range",
+
+			Device: &Device{
+				R:  1,
+				G:  2,
+				B:  3,
+				UV: channel(512),
+			},
+			Error: errors.New("uv channel outside of DMX range (channel=512)"),
+		})
+		validate(t, &testCase{
+			Name: "Collision",
+
+			Device: &Device{
+				R:  1,
+				G:  2,
+				B:  3,
+				W:  channel(4),
+				WW: channel(4),
+			},
+			Error: errors.New("warm white channel collides with white channel (channel=4)"),
+		})
+		validate(t, &testCase{
+			Name: "Fine Range",
+
+			Device: &Device{
+				R:     1,
+				G:     2,
+				B:     3,
+				GFine: channel(512),
+			},
+			Error: errors.New("green fine channel outside of DMX range (channel=512)"),
+		})
+		validate(t, &testCase{
+			Name: "Fine Collision",
+
+			Device: &Device{
+				R:     1,
+				G:     2,
+				B:     3,
+				W:     channel(4),
+				RFine: channel(4),
+			},
+			Error: errors.New("white channel collides with red fine channel (channel=4)"),
+		})
+		validate(t, &testCase{
+			Name: "Extraction",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+				W: channel(4),
+
+				Extraction: "foo",
+			},
+			Error: errors.New("unknown extraction algorithm: foo"),
+		})
+	})
 	validate(t, &testCase{
 		Name: "Static",
 
@@ -78,6 +168,124 @@ func TestDeviceVerify(t *testing.T) {
 		},
 		Error: errors.New("invalid static channel outside of DMX range (channel=512)"),
 	})
+	t.Run("Drivers", func(t *testing.T) {
+		validate(t, &testCase{
+			Name: "Range",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+
+				Drivers: map[uint16]*Driver{
+					512: &Driver{Source: SourceLuma},
+				},
+			},
+			Error: errors.New("driver channel outside of DMX range (channel=512)"),
+		})
+		validate(t, &testCase{
+			Name: "Color Collision",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+
+				Drivers: map[uint16]*Driver{
+					2: &Driver{Source: SourceLuma},
+				},
+			},
+			Error: errors.New("driver channel collides with green channel (channel=2)"),
+		})
+		validate(t, &testCase{
+			Name: "Static Collision",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+
+				Statics: map[uint16]uint8{
+					4: 255,
+				},
+				Drivers: map[uint16]*Driver{
+					4: &Driver{Source: SourceLuma},
+				},
+			},
+			Error: errors.New("driver channel collides with static channel (channel=4)"),
+		})
+		validate(t, &testCase{
+			Name: "Invalid Driver",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+
+				Drivers: map[uint16]*Driver{
+					4: &Driver{Source: "foo"},
+				},
+			},
+			Error: errors.New("unknown driver source: foo"),
+		})
+	})
+	validate(t, &testCase{
+		Name: "Master Exclude",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			MasterExclude: []uint16{1, 5},
+		},
+		Error: errors.New("master excluded channel is not a color channel of the device (channel=5)"),
+	})
+	validate(t, &testCase{
+		Name: "Master Exclude Static",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Statics: map[uint16]uint8{
+				4: 255,
+			},
+			MasterExclude: []uint16{4},
+		},
+		Error: errors.New("master excluded channel is not a color channel of the device (channel=4)"),
+	})
+	validate(t, &testCase{
+		Name: "Master Exclude Driver",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Drivers: map[uint16]*Driver{
+				5: &Driver{Source: SourceLuma},
+			},
+			MasterExclude: []uint16{5},
+		},
+		Error: errors.New("master excluded channel is not a color channel of the device (channel=5)"),
+	})
+	validate(t, &testCase{
+		Name: "Master Include",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Statics: map[uint16]uint8{
+				4: 255,
+			},
+			MasterInclude: []uint16{4, 1},
+		},
+		Error: errors.New("master included channel is not a static or driven channel of the device (channel=1)"),
+	})
 }
 
 func TestDeviceUpdateFrame(t *testing.T) {
@@ -85,13 +293,19 @@ func TestDeviceUpdateFrame(t *testing.T) {
 		Name string
 
 		Device *Device
+		Master float64
 		Frame  DMXFrame
 	}
 
 	validate := func(t *testing.T, tc *testCase) {
 		t.Run(tc.Name, func(t *testing.T) {
+			master := tc.Master
+			if master == 0 {
+				master = 1
+			}
+
 			var frame DMXFrame
-			tc.Device.UpdateFrame(&frame)
+			tc.Device.UpdateFrame(&frame, master)
 			assert.Equal(t, tc.Frame, frame)
 		})
 	}
@@ -104,9 +318,9 @@ func TestDeviceUpdateFrame(t *testing.T) {
 			G: 2,
 			B: 3,
 
-			RValue: 1,
-			GValue: 2,
-			BValue: 3,
+			RValue: 0x0100,
+			GValue: 0x0200,
+			BValue: 0x0300,
 		},
 		Frame: [512]byte{0, 1, 2, 3},
 	})
@@ -128,4 +342,149 @@ func TestDeviceUpdateFrame(t *testing.T) {
 		},
 		Frame: [512]byte{0, 1},
 	})
+	validate(t, &testCase{
+		Name: "Emitters",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+			W: func(c uint16) *uint16 { return &c }(4),
+
+			RValue: 0x0100,
+			GValue: 0x0200,
+			BValue: 0x0300,
+			WValue: 0x0400,
+			// The amber channel is not equipped.
+			AValue: 0x0500,
+		},
+		Frame: [512]byte{0, 1, 2, 3, 4},
+	})
+	validate(t, &testCase{
+		Name: "Fine Channels",
+
+		Device: &Device{
+			R:     1,
+			G:     2,
+			B:     3,
+			RFine: func(c uint16) *uint16 { return &c }(4),
+
+			RValue: 0x1234,
+			GValue: 0x5678,
+		},
+		Frame: [512]byte{0, 0x12, 0x56, 0, 0x34},
+	})
+	validate(t, &testCase{
+		Name: "Rounding",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			RValue: 0x80ff,
+			GValue: 0x807f,
+			BValue: 0xffff,
+		},
+		Frame: [512]byte{0, 0x81, 0x80, 0xff},
+	})
+	validate(t, &testCase{
+		Name: "Drivers",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Drivers: map[uint16]*Driver{
+				4: &Driver{Value: 4},
+			},
+		},
+		Frame: [512]byte{0, 0, 0, 0, 4},
+	})
+	validate(t, &testCase{
+		Name: "Master",
+
+		Device: &Device{
+			R:     1,
+			G:     2,
+			B:     3,
+			RFine: func(c uint16) *uint16 { return &c }(4),
+
+			RValue: 0x1235,
+			GValue: 0xff00,
+			BValue: 0xff00,
+
+			Statics: map[uint16]uint8{
+				5: 200,
+				6: 200,
+			},
+			Drivers: map[uint16]*Driver{
+				7: &Driver{Value: 100},
+			},
+			MasterExclude: []uint16{3},
+			MasterInclude: []uint16{5, 7},
+		},
+		Master: 0.5,
+		Frame:  [512]byte{0, 0x09, 0x80, 0xff, 0x1b, 100, 200, 50},
+	})
+	validate(t, &testCase{
+		Name: "Master Exclude Fine",
+
+		Device: &Device{
+			R:     1,
+			G:     2,
+			B:     3,
+			RFine: func(c uint16) *uint16 { return &c }(4),
+
+			RValue: 0x1235,
+			GValue: 0xff00,
+
+			MasterExclude: []uint16{4},
+		},
+		Master: 0.5,
+		Frame:  [512]byte{0, 0x12, 0x80, 0, 0x35},
+	})
+	validate(t, &testCase{
+		Name: "Master Statics And Drivers",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			RValue: 0xffff,
+
+			Statics: map[uint16]uint8{
+				0: 255,
+			},
+			Drivers: map[uint16]*Driver{
+				4: &Driver{Value: 100},
+			},
+		},
+		Master: 0.5,
+		Frame:  [512]byte{255, 0x80, 0, 0, 100},
+	})
+}
+
+func TestDeviceChannels(t *testing.T) {
+	white := uint16(7)
+	blueFine := uint16(4)
+
+	d := &Device{
+		R:     3,
+		G:     1,
+		B:     2,
+		W:     &white,
+		BFine: &blueFine,
+
+		Statics: map[uint16]uint8{
+			0: 255,
+		},
+		Drivers: map[uint16]*Driver{
+			5: &Driver{},
+		},
+	}
+
+	assert.Equal(t, []uint16{0, 1, 2, 3, 4, 5, 7}, d.Channels())
 }
@@ -0,0 +1,107 @@
+package dmx
+
+import (
+	"fmt"
+	"math"
+)
+
+// Source identifies the screen measure driving a channel.
+type Source string
+
+const (
+	// SourceLuma drives a channel with the average luma of the screen area.
+	SourceLuma Source = "luma"
+	// SourceMax drives a channel with the luma of the brightest pixel of the screen area.
+	SourceMax Source = "max"
+	// SourceFlash drives a channel to its maximum while the screen area flashes.
+	SourceFlash Source = "flash"
+	// SourceCentroidX drives a channel with the horizontal position of the brightest region of the screen area.
+	SourceCentroidX Source = "centroid-x"
+	// SourceCentroidY drives a channel with the vertical position of the brightest region of the screen area.
+	SourceCentroidY Source = "centroid-y"
+)
+
+// defaultFlashThreshold holds the luma increase between two updates that is detected as a flash by default.
+const defaultFlashThreshold = 0.3
+
+// Measures holds the measures of a screen area, all between 0 and 1.
+type Measures struct {
+	// Luma holds the average luma.
+	Luma float64
+	// Max holds the luma of the brightest pixel.
+	Max float64
+	// CentroidX holds the horizontal position of the luma weighted centroid.
+	CentroidX float64
+	// CentroidY holds the vertical position of the luma weighted centroid.
+	CentroidY float64
+}
+
+// Driver computes the value of a non-color channel from the measures of a screen area.
+type Driver struct {
+	// Source holds the measure driving the channel.
+	Source Source
+	// Min holds the channel value a measure of 0 maps to.
+	Min uint8
+	// Max holds the channel value a measure of 1 maps to, 255 if omitted.
+	Max *uint8 `json:",omitempty"`
+	// FlashThreshold holds the luma increase between two updates that is detected as a flash, 0.3 if omitted.
+	FlashThreshold float64 `json:",omitempty"`
+
+	// Value holds the current channel value.
+	Value uint8 `json:"-"`
+
+	// luma holds the luma of the previous update for the flash detection.
+	luma float64
+	// initialized is set after the first update.
+	initialized bool
+}
+
+// Verify checks if the Driver is a valid channel driver.
+func (d *Driver) Verify() error {
+	switch d.Source {
+	case SourceLuma, SourceMax, SourceFlash, SourceCentroidX, SourceCentroidY:
+	default:
+		return fmt.Errorf("unknown driver source: %s", d.Source)
+	}
+
+	if d.FlashThreshold < 0 || d.FlashThreshold > 1 {
+		return fmt.Errorf("invalid flash threshold (%v)", d.FlashThreshold)
+	}
+
+	return nil
+}
+
+// Update computes the channel value from the given measures.
+func (d *Driver) Update(m Measures) {
+	var measure float64
+
+	switch d.Source {
+	case SourceLuma:
+		measure = m.Luma
+	case SourceMax:
+		measure = m.Max
+	case SourceFlash:
+		threshold := d.FlashThreshold
+		if threshold == 0 {
+			threshold = defaultFlashThreshold
+		}
+
+		if d.initialized && m.Luma-d.luma >= threshold {
+			measure = 1
+		}
+		d.luma = m.Luma
+		d.initialized = true
+	case SourceCentroidX:
+		measure = m.CentroidX
+	case SourceCentroidY:
+		measure = m.CentroidY
+	}
+
+	max := uint8(255)
+	if d.Max != nil {
+		max = *d.Max
+	}
+
+	measure = math.Max(0, math.Min(1, measure))
+	d.Value = uint8(math.Round(float64(d.Min) + measure*(float64(max)-float64(d.Min))))
+}
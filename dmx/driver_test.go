@@ -0,0 +1,124 @@
+package dmx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDriverVerify(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Driver *Driver
+		Error  error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Driver.Verify()
+			assert.Equal(t, tc.Error, err)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Driver: &Driver{
+			Source:         SourceFlash,
+			FlashThreshold: 0.5,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Source",
+
+		Driver: &Driver{
+			Source: "foo",
+		},
+		Error: errors.New("unknown driver source: foo"),
+	})
+	validate(t, &testCase{
+		Name: "Flash Threshold",
+
+		Driver: &Driver{
+			Source:         SourceFlash,
+			FlashThreshold: 2,
+		},
+		Error: errors.New("invalid flash threshold (2)"),
+	})
+}
+
+func TestDriverUpdate(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Driver   *Driver
+		Measures []Measures
+		Values   []uint8
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			for i, m := range tc.Measures {
+				tc.Driver.Update(m)
+				assert.Equal(t, tc.Values[i], tc.Driver.Value, "update %d", i)
+			}
+		})
+	}
+
+	max := func(v uint8) *uint8 {
+		return &v
+	}
+
+	validate(t, &testCase{
+		Name: "Luma",
+
+		Driver: &Driver{
+			Source: SourceLuma,
+		},
+		Measures: []Measures{{Luma: 0}, {Luma: 0.5}, {Luma: 1}},
+		Values:   []uint8{0, 128, 255},
+	})
+	validate(t, &testCase{
+		Name: "Max",
+
+		Driver: &Driver{
+			Source: SourceMax,
+		},
+		Measures: []Measures{{Luma: 0.1, Max: 0.2}},
+		Values:   []uint8{51},
+	})
+	validate(t, &testCase{
+		Name: "Range",
+
+		Driver: &Driver{
+			Source: SourceCentroidX,
+			Min:    100,
+			Max:    max(200),
+		},
+		Measures: []Measures{{CentroidX: 0}, {CentroidX: 0.25}, {CentroidX: 1}},
+		Values:   []uint8{100, 125, 200},
+	})
+	validate(t, &testCase{
+		Name: "Inverted Range",
+
+		Driver: &Driver{
+			Source: SourceCentroidY,
+			Min:    255,
+			Max:    max(0),
+		},
+		Measures: []Measures{{CentroidY: 0}, {CentroidY: 1}},
+		Values:   []uint8{255, 0},
+	})
+	validate(t, &testCase{
+		Name: "Flash",
+
+		Driver: &Driver{
+			Source: SourceFlash,
+			Max:    max(250),
+		},
+		Measures: []Measures{{Luma: 0.8}, {Luma: 0.1}, {Luma: 0.3}, {Luma: 0.7}, {Luma: 0.7}},
+		Values:   []uint8{0, 0, 0, 250, 0},
+	})
+}
@@ -0,0 +1,95 @@
+package dmx
+
+import (
+	"fmt"
+	"math"
+)
+
+// Extraction defines how the additional emitters of a device are derived from an RGB color.
+type Extraction string
+
+const (
+	// ExtractionNone leaves the additional emitters dark and outputs the RGB color as is.
+	ExtractionNone Extraction = ""
+	// ExtractionAdditive drives the additional emitters with their share of the color while keeping the RGB color.
+	ExtractionAdditive Extraction = "additive"
+	// ExtractionSubtractive moves the share of the additional emitters out of the RGB color.
+	ExtractionSubtractive Extraction = "subtractive"
+)
+
+// amberRatio holds the green to red ratio of the light of an amber emitter.
+const amberRatio = 0.75
+
+// uvRatio holds the red to blue ratio of the light of an ultraviolet emitter.
+const uvRatio = 0.5
+
+// Verify checks if the Extraction is a known algorithm.
+func (e Extraction) Verify() error {
+	switch e {
+	case ExtractionNone, ExtractionAdditive, ExtractionSubtractive:
+		return nil
+	}
+
+	return fmt.Errorf("unknown extraction algorithm: %s", e)
+}
+
+// SetColor sets the channel values of the device to display the given 16-bit color.
+func (d *Device) SetColor(r uint16, g uint16, b uint16) {
+	red, green, blue := float64(r), float64(g), float64(b)
+	var white, coldWhite, warmWhite, amber, uv float64
+
+	if d.Extraction != ExtractionNone {
+		if d.W != nil || d.CW != nil || d.WW != nil {
+			w := math.Min(red, math.Min(green, blue))
+
+			switch {
+			case d.W != nil:
+				white = w
+			case d.CW != nil && d.WW != nil:
+				// Split the white by the tint of the original color.
+				if red+blue == 0 {
+					coldWhite, warmWhite = w/2, w/2
+				} else {
+					coldWhite, warmWhite = w*blue/(red+blue), w*red/(red+blue)
+				}
+			case d.CW != nil:
+				coldWhite = w
+			default:
+				warmWhite = w
+			}
+
+			if d.Extraction == ExtractionSubtractive {
+				red, green, blue = red-w, green-w, blue-w
+			}
+		}
+
+		if d.A != nil {
+			amber = math.Min(red, green/amberRatio)
+
+			if d.Extraction == ExtractionSubtractive {
+				red, green = red-amber, green-amber*amberRatio
+			}
+		}
+
+		if d.UV != nil {
+			uv = math.Min(red/uvRatio, blue)
+
+			if d.Extraction == ExtractionSubtractive {
+				red, blue = red-uv*uvRatio, blue-uv
+			}
+		}
+	}
+
+	d.RValue = toValue(red)
+	d.GValue = toValue(green)
+	d.BValue = toValue(blue)
+	d.WValue = toValue(white)
+	d.AValue = toValue(amber)
+	d.UVValue = toValue(uv)
+	d.CWValue = toValue(coldWhite)
+	d.WWValue = toValue(warmWhite)
+}
+
+func toValue(v float64) uint16 {
+	return uint16(math.Round(math.Max(0, math.Min(math.MaxUint16, v))))
+}
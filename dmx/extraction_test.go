@@ -0,0 +1,254 @@
+package dmx
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractionVerify(t *testing.T) {
+	assert.NoError(t, ExtractionNone.Verify())
+	assert.NoError(t, ExtractionAdditive.Verify())
+	assert.NoError(t, ExtractionSubtractive.Verify())
+	assert.Equal(t, errors.New("unknown extraction algorithm: foo"), Extraction("foo").Verify())
+}
+
+func TestDeviceSetColor(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Device *Device
+		Color  [3]uint16
+
+		Expected *Device
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			tc.Device.SetColor(tc.Color[0], tc.Color[1], tc.Color[2])
+			assert.Equal(t, tc.Expected, tc.Device)
+		})
+	}
+
+	channel := func(c uint16) *uint16 {
+		return &c
+	}
+
+	validate(t, &testCase{
+		Name: "RGB",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Extraction: ExtractionSubtractive,
+		},
+		Color: [3]uint16{2570, 5140, 7710},
+
+		Expected: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+
+			Extraction: ExtractionSubtractive,
+
+			RValue: 2570,
+			GValue: 5140,
+			BValue: 7710,
+		},
+	})
+	validate(t, &testCase{
+		Name: "No Extraction",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+			W: channel(4),
+		},
+		Color: [3]uint16{2570, 5140, 7710},
+
+		Expected: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+			W: channel(4),
+
+			RValue: 2570,
+			GValue: 5140,
+			BValue: 7710,
+		},
+	})
+	t.Run("White", func(t *testing.T) {
+		validate(t, &testCase{
+			Name: "Additive",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+				W: channel(4),
+
+				Extraction: ExtractionAdditive,
+			},
+			Color: [3]uint16{2570, 5140, 7710},
+
+			Expected: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+				W: channel(4),
+
+				Extraction: ExtractionAdditive,
+
+				RValue: 2570,
+				GValue: 5140,
+				BValue: 7710,
+				WValue: 2570,
+			},
+		})
+		validate(t, &testCase{
+			Name: "Subtractive",
+
+			Device: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+				W: channel(4),
+
+				Extraction: ExtractionSubtractive,
+			},
+			Color: [3]uint16{2570, 5140, 7710},
+
+			Expected: &Device{
+				R: 1,
+				G: 2,
+				B: 3,
+				W: channel(4),
+
+				Extraction: ExtractionSubtractive,
+
+				RValue: 0,
+				GValue: 2570,
+				BValue: 5140,
+				WValue: 2570,
+			},
+		})
+		validate(t, &testCase{
+			Name: "Cold and Warm",
+
+			Device: &Device{
+				R:  1,
+				G:  2,
+				B:  3,
+				CW: channel(4),
+				WW: channel(5),
+
+				Extraction: ExtractionSubtractive,
+			},
+			Color: [3]uint16{25700, 25700, 25700},
+
+			Expected: &Device{
+				R:  1,
+				G:  2,
+				B:  3,
+				CW: channel(4),
+				WW: channel(5),
+
+				Extraction: ExtractionSubtractive,
+
+				CWValue: 12850,
+				WWValue: 12850,
+			},
+		})
+	})
+	validate(t, &testCase{
+		Name: "Amber",
+
+		Device: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+			A: channel(4),
+
+			Extraction: ExtractionSubtractive,
+		},
+		Color: [3]uint16{51400, 19275, 0},
+
+		Expected: &Device{
+			R: 1,
+			G: 2,
+			B: 3,
+			A: channel(4),
+
+			Extraction: ExtractionSubtractive,
+
+			RValue: 25700,
+			GValue: 0,
+			BValue: 0,
+			AValue: 25700,
+		},
+	})
+	validate(t, &testCase{
+		Name: "UV",
+
+		Device: &Device{
+			R:  1,
+			G:  2,
+			B:  3,
+			UV: channel(4),
+
+			Extraction: ExtractionSubtractive,
+		},
+		Color: [3]uint16{12850, 0, 51400},
+
+		Expected: &Device{
+			R:  1,
+			G:  2,
+			B:  3,
+			UV: channel(4),
+
+			Extraction: ExtractionSubtractive,
+
+			RValue:  0,
+			GValue:  0,
+			BValue:  25700,
+			UVValue: 25700,
+		},
+	})
+	validate(t, &testCase{
+		Name: "RGBWAUV",
+
+		Device: &Device{
+			R:  1,
+			G:  2,
+			B:  3,
+			W:  channel(4),
+			A:  channel(5),
+			UV: channel(6),
+
+			Extraction: ExtractionSubtractive,
+		},
+		Color: [3]uint16{65535, 26985, 14135},
+
+		Expected: &Device{
+			R:  1,
+			G:  2,
+			B:  3,
+			W:  channel(4),
+			A:  channel(5),
+			UV: channel(6),
+
+			Extraction: ExtractionSubtractive,
+
+			RValue:  34267,
+			GValue:  0,
+			BValue:  0,
+			WValue:  14135,
+			AValue:  17133,
+			UVValue: 0,
+		},
+	})
+}
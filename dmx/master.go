@@ -0,0 +1,78 @@
+package dmx
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Master holds a master intensity between 0 and 1, fading linearly between values over time.
+type Master struct {
+	// lock guards the fields below.
+	lock sync.Mutex
+
+	// from holds the intensity at the start of the fade.
+	from float64
+	// to holds the target intensity of the fade.
+	to float64
+	// start holds the time the fade started.
+	start time.Time
+	// duration holds the duration of the fade.
+	duration time.Duration
+}
+
+// NewMaster returns a new master at full intensity.
+func NewMaster() *Master {
+	return &Master{
+		from: 1,
+		to:   1,
+	}
+}
+
+// Set fades from the current to the given intensity over the given duration, starting at the given time.
+func (m *Master) Set(level float64, fade time.Duration, now time.Time) error {
+	if level < 0 || level > 1 {
+		return fmt.Errorf("invalid master intensity (master=%v)", level)
+	}
+	if fade < 0 {
+		return fmt.Errorf("invalid master fade (fade=%v)", fade)
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.from = m.level(now)
+	m.to = level
+	m.start = now
+	m.duration = fade
+
+	return nil
+}
+
+// Level returns the intensity at the given time.
+func (m *Master) Level(now time.Time) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.level(now)
+}
+
+// Target returns the intensity the master is fading to.
+func (m *Master) Target() float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.to
+}
+
+func (m *Master) level(now time.Time) float64 {
+	elapsed := now.Sub(m.start)
+	if m.duration <= 0 || elapsed >= m.duration {
+		return m.to
+	}
+	if elapsed <= 0 {
+		return m.from
+	}
+
+	return m.from + (m.to-m.from)*float64(elapsed)/float64(m.duration)
+}
@@ -0,0 +1,31 @@
+package dmx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaster(t *testing.T) {
+	start := time.Unix(0, 0)
+	m := NewMaster()
+	assert.Equal(t, 1.0, m.Level(start))
+
+	assert.EqualError(t, m.Set(1.5, 0, start), "invalid master intensity (master=1.5)")
+	assert.EqualError(t, m.Set(0.5, -time.Second, start), "invalid master fade (fade=-1s)")
+
+	assert.NoError(t, m.Set(0.5, 0, start))
+	assert.Equal(t, 0.5, m.Level(start))
+
+	assert.NoError(t, m.Set(1, 2*time.Second, start))
+	assert.Equal(t, 1.0, m.Target())
+	assert.Equal(t, 0.5, m.Level(start.Add(-time.Second)))
+	assert.Equal(t, 0.75, m.Level(start.Add(time.Second)))
+	assert.Equal(t, 1.0, m.Level(start.Add(3*time.Second)))
+
+	// A new fade starts from the current intensity.
+	assert.NoError(t, m.Set(0, time.Second, start.Add(time.Second)))
+	assert.Equal(t, 0.375, m.Level(start.Add(1500*time.Millisecond)))
+	assert.Equal(t, 0.0, m.Level(start.Add(2*time.Second)))
+}
@@ -4,8 +4,8 @@ import "fmt"
 
 // Universe holds a DMX universe.
 type Universe struct {
-	// Devices holds the devices of this universe.
-	Devices []*Device
+	// Devices holds the devices of this universe, assigned by the configuration instead of declared with the universe.
+	Devices []*Device `json:"-"`
 
 	// Net holds the ArtNet net, a group of 16 consecutive sub-nets or 256 consecutive universes.
 	Net uint8
@@ -22,16 +22,47 @@ func (u *Universe) Verify() error {
 		return fmt.Errorf("invalid ArtNet subnet (subnet=%v)", u.SubNet)
 	}
 
+	for _, d := range u.Devices {
+		if err := d.Verify(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-// SendColorUpdate sends a color update from the universe devices over the given controller.
-func (u *Universe) SendColorUpdate(controller *ArtNetController) error {
+// Sender sends DMX frames, e.g. an ArtNetController.
+type Sender interface {
+	// SendDMX sends the DMX frame to the given net and sub-net.
+	SendDMX(frame DMXFrame, net uint8, sub uint8) error
+}
+
+// Frame returns the DMX frame of the universe devices, scaled by the master intensity between 0 and 1.
+func (u *Universe) Frame(master float64) DMXFrame {
 	var frame DMXFrame
 
 	for _, d := range u.Devices {
-		d.UpdateFrame(&frame)
+		d.UpdateFrame(&frame, master)
+	}
+
+	return frame
+}
+
+// FineChannels returns the fine channels of the universe devices by their coarse channel.
+func (u *Universe) FineChannels() map[uint16]uint16 {
+	fine := map[uint16]uint16{}
+	for _, d := range u.Devices {
+		for _, c := range d.colorChannels() {
+			if c.fine != nil {
+				fine[*c.coarse] = *c.fine
+			}
+		}
 	}
 
-	return controller.SendDMX(frame, u.Net, u.SubNet)
+	return fine
+}
+
+// SendColorUpdate sends a color update from the universe devices, scaled by the master intensity between 0 and 1, over the given sender.
+func (u *Universe) SendColorUpdate(controller Sender, master float64) error {
+	return controller.SendDMX(u.Frame(master), u.Net, u.SubNet)
 }
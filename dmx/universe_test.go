@@ -38,4 +38,18 @@ func TestUniverseVerify(t *testing.T) {
 		},
 		Error: errors.New("invalid ArtNet subnet (subnet=16)"),
 	})
+	validate(t, &testCase{
+		Name: "Invalid Device",
+
+		Universe: &Universe{
+			Devices: []*Device{
+				&Device{
+					R: 1,
+					G: 1,
+					B: 2,
+				},
+			},
+		},
+		Error: errors.New("color channels should be different (r=1, g=1, b=2)"),
+	})
 }
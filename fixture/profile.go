@@ -0,0 +1,160 @@
+package fixture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// Profile holds the channel layout of a fixture type.
+type Profile struct {
+	// Name holds the name the profile is referenced by.
+	Name string
+	// Channels holds the number of DMX channels the fixture occupies.
+	Channels uint16
+	// Roles holds the channel offsets of the color roles, named like the channels of a device (e.g. "Red" or "WhiteFine").
+	Roles map[string]uint16
+	// Statics holds the default static values per channel offset, e.g. a dimmer at full or a disabled strobe.
+	Statics map[uint16]uint8
+	// Extraction holds the algorithm deriving the additional emitters from the color.
+	Extraction dmx.Extraction
+	// MasterExclude holds the color channel offsets that are not scaled by the master intensity.
+	MasterExclude []uint16 `json:",omitempty"`
+	// MasterInclude holds the static channel offsets that are scaled by the master intensity, e.g. a dimmer.
+	MasterInclude []uint16 `json:",omitempty"`
+}
+
+// Verify checks if the Profile is a valid fixture profile.
+func (p *Profile) Verify() error {
+	if p.Name == "" {
+		return fmt.Errorf("profile without name")
+	}
+	if p.Channels == 0 || p.Channels > 512 {
+		return fmt.Errorf("invalid channel count of profile %s (channels=%v)", p.Name, p.Channels)
+	}
+
+	for _, role := range []string{"Red", "Green", "Blue"} {
+		if _, ok := p.Roles[role]; !ok {
+			return fmt.Errorf("profile %s lacks a %s channel", p.Name, strings.ToLower(role))
+		}
+	}
+	for role, offset := range p.Roles {
+		if offset >= p.Channels {
+			return fmt.Errorf("%s channel of profile %s outside of fixture (offset=%v)", role, p.Name, offset)
+		}
+	}
+	for offset := range p.Statics {
+		if offset >= p.Channels {
+			return fmt.Errorf("static channel of profile %s outside of fixture (offset=%v)", p.Name, offset)
+		}
+	}
+
+	// Validate the roles and the channel collisions on a device at the first address.
+	_, err := p.Device(0)
+
+	return err
+}
+
+// Device returns a new device of the profile starting at the given channel.
+func (p *Profile) Device(address uint16) (*dmx.Device, error) {
+	d := &dmx.Device{
+		Extraction: p.Extraction,
+		Statics:    map[uint16]uint8{},
+	}
+
+	for role, offset := range p.Roles {
+		channel := address + offset
+
+		switch role {
+		case "Red":
+			d.R = channel
+		case "Green":
+			d.G = channel
+		case "Blue":
+			d.B = channel
+		case "White":
+			d.W = &channel
+		case "Amber":
+			d.A = &channel
+		case "UV":
+			d.UV = &channel
+		case "ColdWhite":
+			d.CW = &channel
+		case "WarmWhite":
+			d.WW = &channel
+		case "RedFine":
+			d.RFine = &channel
+		case "GreenFine":
+			d.GFine = &channel
+		case "BlueFine":
+			d.BFine = &channel
+		case "WhiteFine":
+			d.WFine = &channel
+		case "AmberFine":
+			d.AFine = &channel
+		case "UVFine":
+			d.UVFine = &channel
+		case "ColdWhiteFine":
+			d.CWFine = &channel
+		case "WarmWhiteFine":
+			d.WWFine = &channel
+		default:
+			return nil, fmt.Errorf("unknown role %s in profile %s", role, p.Name)
+		}
+	}
+
+	for offset, value := range p.Statics {
+		d.Statics[address+offset] = value
+	}
+	for _, offset := range p.MasterExclude {
+		d.MasterExclude = append(d.MasterExclude, address+offset)
+	}
+	for _, offset := range p.MasterInclude {
+		d.MasterInclude = append(d.MasterInclude, address+offset)
+	}
+
+	if err := d.Verify(); err != nil {
+		return nil, fmt.Errorf("invalid device of profile %s at address %v: %v", p.Name, address, err)
+	}
+
+	return d, nil
+}
+
+// LoadProfiles reads all ".json" fixture profiles of the given directory.
+func LoadProfiles(dir string) (map[string]*Profile, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	profiles := map[string]*Profile{}
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".json" {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var p Profile
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, fmt.Errorf("cannot parse profile %s: %v", f.Name(), err)
+		}
+		if err := p.Verify(); err != nil {
+			return nil, err
+		}
+
+		if _, ok := profiles[p.Name]; ok {
+			return nil, fmt.Errorf("duplicate profile: %s", p.Name)
+		}
+		profiles[p.Name] = &p
+	}
+
+	return profiles, nil
+}
@@ -0,0 +1,195 @@
+package fixture
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileVerify(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Profile *Profile
+		Error   error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Profile.Verify()
+			assert.Equal(t, tc.Error, err)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 4,
+			Roles: map[string]uint16{
+				"Red":   1,
+				"Green": 2,
+				"Blue":  3,
+			},
+			Statics: map[uint16]uint8{
+				0: 255,
+			},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Name",
+
+		Profile: &Profile{},
+		Error:   errors.New("profile without name"),
+	})
+	validate(t, &testCase{
+		Name: "Channel Count",
+
+		Profile: &Profile{
+			Name: "rgb",
+		},
+		Error: errors.New("invalid channel count of profile rgb (channels=0)"),
+	})
+	validate(t, &testCase{
+		Name: "Missing Role",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 3,
+			Roles: map[string]uint16{
+				"Red":  0,
+				"Blue": 2,
+			},
+		},
+		Error: errors.New("profile rgb lacks a green channel"),
+	})
+	validate(t, &testCase{
+		Name: "Role Outside",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 3,
+			Roles: map[string]uint16{
+				"Red":   0,
+				"Green": 1,
+				"Blue":  3,
+			},
+		},
+		Error: errors.New("Blue channel of profile rgb outside of fixture (offset=3)"),
+	})
+	validate(t, &testCase{
+		Name: "Static Outside",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 3,
+			Roles: map[string]uint16{
+				"Red":   0,
+				"Green": 1,
+				"Blue":  2,
+			},
+			Statics: map[uint16]uint8{
+				3: 0,
+			},
+		},
+		Error: errors.New("static channel of profile rgb outside of fixture (offset=3)"),
+	})
+	validate(t, &testCase{
+		Name: "Unknown Role",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 4,
+			Roles: map[string]uint16{
+				"Red":    0,
+				"Green":  1,
+				"Blue":   2,
+				"Strobe": 3,
+			},
+		},
+		Error: errors.New("unknown role Strobe in profile rgb"),
+	})
+	validate(t, &testCase{
+		Name: "Collision",
+
+		Profile: &Profile{
+			Name:     "rgb",
+			Channels: 3,
+			Roles: map[string]uint16{
+				"Red":   0,
+				"Green": 1,
+				"Blue":  1,
+			},
+		},
+		Error: errors.New("invalid device of profile rgb at address 0: color channels should be different (r=0, g=1, b=1)"),
+	})
+}
+
+func TestProfileDevice(t *testing.T) {
+	p := &Profile{
+		Name:     "rgbw",
+		Channels: 6,
+		Roles: map[string]uint16{
+			"Red":     1,
+			"Green":   2,
+			"Blue":    3,
+			"White":   4,
+			"RedFine": 5,
+		},
+		Statics: map[uint16]uint8{
+			0: 255,
+		},
+		Extraction:    dmx.ExtractionAdditive,
+		MasterExclude: []uint16{4},
+	}
+
+	d, err := p.Device(17)
+	assert.NoError(t, err)
+
+	white := uint16(21)
+	redFine := uint16(22)
+	assert.Equal(t, &dmx.Device{
+		R:     18,
+		G:     19,
+		B:     20,
+		W:     &white,
+		RFine: &redFine,
+
+		Extraction: dmx.ExtractionAdditive,
+
+		Statics: map[uint16]uint8{
+			17: 255,
+		},
+		MasterExclude: []uint16{21},
+	}, d)
+
+	_, err = p.Device(510)
+	assert.EqualError(t, err, "invalid device of profile rgbw at address 510: green channel outside of DMX range (channel=512)")
+}
+
+func TestLoadProfiles(t *testing.T) {
+	t.Run("Bundled", func(t *testing.T) {
+		profiles, err := LoadProfiles(filepath.Join("..", "profiles"))
+		assert.NoError(t, err)
+		assert.Contains(t, profiles, "generic-rgbw-8ch")
+	})
+	t.Run("Duplicate", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "profiles")
+		assert.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		data := []byte(`{"Name": "rgb", "Channels": 3, "Roles": {"Red": 0, "Green": 1, "Blue": 2}}`)
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "a.json"), data, 0644))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "b.json"), data, 0644))
+		assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README"), []byte("ignored"), 0644))
+
+		_, err = LoadProfiles(dir)
+		assert.EqualError(t, err, "duplicate profile: rgb")
+	})
+}
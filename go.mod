@@ -1,12 +1,24 @@
 module github.com/bauersimon/ScreenToArtNet
 
-go 1.13
+go 1.21
 
 require (
-	github.com/BurntSushi/xgb v0.0.0-20200324125942-20f126ea2843 // indirect
-	github.com/gen2brain/shm v0.0.0-20200228170931-49f9650110c5 // indirect
+	github.com/BurntSushi/toml v1.3.2
+	github.com/BurntSushi/xgb v0.0.0-20200324125942-20f126ea2843
+	github.com/eclipse/paho.mqtt.golang v1.4.3
 	github.com/jsimonetti/go-artnet v0.0.0-20200505065931-a2614ed858e3
 	github.com/kbinani/screenshot v0.0.0-20191211154542-3a185f1ce18f
-	github.com/lxn/win v0.0.0-20191128105842-2da648fda5b4 // indirect
 	github.com/stretchr/testify v1.6.1
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/gen2brain/shm v0.0.0-20200228170931-49f9650110c5 // indirect
+	github.com/gorilla/websocket v1.5.0 // indirect
+	github.com/lxn/win v0.0.0-20191128105842-2da648fda5b4 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.8.0 // indirect
+	golang.org/x/sync v0.1.0 // indirect
+	golang.org/x/sys v0.6.0 // indirect
 )
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"image"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// prompter asks questions on the command line, falling back to the defaults at the end of the input.
+type prompter struct {
+	in  *bufio.Scanner
+	out io.Writer
+}
+
+// ask asks the question and returns the answer or the default for an empty answer.
+func (p *prompter) ask(question string, fallback string) string {
+	fmt.Fprintf(p.out, "%s [%s]: ", question, fallback)
+	if !p.in.Scan() {
+		fmt.Fprintln(p.out)
+
+		return fallback
+	}
+
+	if answer := strings.TrimSpace(p.in.Text()); answer != "" {
+		return answer
+	}
+
+	return fallback
+}
+
+// askInt asks the question until the answer is a number within the given range.
+func (p *prompter) askInt(question string, fallback int, min int, max int) int {
+	for {
+		answer, err := strconv.Atoi(p.ask(question, strconv.Itoa(fallback)))
+		if err == nil && answer >= min && answer <= max {
+			return answer
+		}
+
+		fmt.Fprintf(p.out, "please enter a number between %d and %d\n", min, max)
+	}
+}
+
+// intAnswer returns the answer given by a flag or else asks for it, both within the given range.
+func (p *prompter) intAnswer(answer *int, question string, fallback int, min int, max int) (int, error) {
+	if answer == nil {
+		return p.askInt(question, fallback, min, max), nil
+	}
+	if *answer < min || *answer > max {
+		return 0, fmt.Errorf("invalid %s, expected a number between %d and %d: %d", question, min, max, *answer)
+	}
+
+	return *answer, nil
+}
+
+// stringAnswer returns the answer given by a flag or else asks for it.
+func (p *prompter) stringAnswer(answer *string, question string, fallback string) string {
+	if answer == nil {
+		return p.ask(question, fallback)
+	}
+
+	return *answer
+}
+
+// initAnswers holds the answers given by flags instead of the wizard, nil answers are asked.
+type initAnswers struct {
+	Monitor *int
+	LEDs    *string
+	Order   *string
+	Depth   *int
+	Net     *int
+	SubNet  *int
+	Address *int
+	Node    *string
+}
+
+// initFlags returns the answers of the configuration wizard given by flags or environment variables.
+func initFlags(flags *flag.FlagSet, sources map[string]string) initAnswers {
+	var answers initAnswers
+	for name, answer := range map[string]func(){
+		"screen":  func() { answers.Monitor = &args.Screen },
+		"leds":    func() { answers.LEDs = &args.LEDs },
+		"order":   func() { answers.Order = &args.Order },
+		"depth":   func() { answers.Depth = &args.Depth },
+		"net":     func() { answers.Net = &args.Net },
+		"subnet":  func() { answers.SubNet = &args.SubNet },
+		"address": func() { answers.Address = &args.Address },
+		"dst":     func() { answers.Node = &args.Dst },
+	} {
+		if flagSet(flags, name) || sources[name] == sourceEnv {
+			answer()
+		}
+	}
+
+	return answers
+}
+
+// initConfig asks for the LED layout around one of the given monitors and writes the configuration.
+func initConfig(in io.Reader, out io.Writer, configPath string, monitors []image.Rectangle, answers initAnswers) error {
+	p := &prompter{in: bufio.NewScanner(in), out: out}
+
+	if err := printMonitors(out, monitors); err != nil {
+		return err
+	}
+	monitor, err := p.intAnswer(answers.Monitor, "monitor", 0, 0, len(monitors)-1)
+	if err != nil {
+		return err
+	}
+	size := monitors[monitor].Size()
+
+	layout := &ambilight.Layout{
+		Screen: size,
+	}
+	if answers.LEDs != nil {
+		counts := strings.Split(*answers.LEDs, ",")
+		if len(counts) != 4 {
+			return fmt.Errorf("invalid LED counts, expected top,right,bottom,left: %s", *answers.LEDs)
+		}
+		for i, edge := range []*int{&layout.Top, &layout.Right, &layout.Bottom, &layout.Left} {
+			count, err := strconv.Atoi(strings.TrimSpace(counts[i]))
+			if err != nil {
+				return fmt.Errorf("invalid LED counts, expected top,right,bottom,left: %s", *answers.LEDs)
+			}
+			*edge = count
+		}
+	} else {
+		layout.Top = p.askInt("LEDs along the top edge", 0, 0, 512)
+		layout.Right = p.askInt("LEDs along the right edge", 0, 0, 512)
+		layout.Bottom = p.askInt("LEDs along the bottom edge", 0, 0, 512)
+		layout.Left = p.askInt("LEDs along the left edge", 0, 0, 512)
+	}
+	layout.Order = p.stringAnswer(answers.Order, "channel order of a LED", "GRB")
+
+	fallbackDepth := size.X
+	if size.Y < fallbackDepth {
+		fallbackDepth = size.Y
+	}
+	fallbackDepth /= 10
+	if fallbackDepth < 1 {
+		fallbackDepth = 1
+	}
+	if layout.Depth, err = p.intAnswer(answers.Depth, "sampled depth in pixels", fallbackDepth, 1, size.X+size.Y); err != nil {
+		return err
+	}
+
+	net, err := p.intAnswer(answers.Net, "ArtNet net", 0, 0, 127)
+	if err != nil {
+		return err
+	}
+	subNet, err := p.intAnswer(answers.SubNet, "ArtNet sub-net", 0, 0, 15)
+	if err != nil {
+		return err
+	}
+	layout.Universe = dmx.Universe{Net: uint8(net), SubNet: uint8(subNet)}
+	address, err := p.intAnswer(answers.Address, "first DMX channel", 0, 0, 511)
+	if err != nil {
+		return err
+	}
+	layout.Address = uint16(address)
+	node := p.stringAnswer(answers.Node, "IP address of the ArtNet node", "2.0.0.1")
+	layout.Settings = ambilight.Settings{
+		Dst:    &node,
+		Screen: &monitor,
+	}
+
+	if err := ambilight.WriteLayout(configPath, layout); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "configuration written to %s\n", configPath)
+	fmt.Fprintf(out, "start the ambilight with: screentoartnet run -config %s\n", configPath)
+
+	return nil
+}
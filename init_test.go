@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitConfig(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Input   string
+		Answers initAnswers
+
+		ExpectedOutput    []string
+		ExpectedSettings  ambilight.Settings
+		ExpectedUniverses int
+		ExpectedDevices   int
+		Error             string
+	}
+
+	monitors := []image.Rectangle{
+		image.Rect(0, 0, 1920, 1080),
+		image.Rect(1920, 0, 1920+2560, 1440),
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			configPath := filepath.Join(t.TempDir(), "config.yaml")
+			var output bytes.Buffer
+
+			err := initConfig(strings.NewReader(tc.Input), &output, configPath, monitors, tc.Answers)
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+
+				return
+			}
+			require.NoError(t, err)
+
+			for _, expected := range tc.ExpectedOutput {
+				assert.Contains(t, output.String(), expected)
+			}
+
+			settings, err := ambilight.ReadSettings(configPath)
+			require.NoError(t, err)
+			assert.Equal(t, tc.ExpectedSettings, *settings)
+
+			config, err := ambilight.ReadConfig(configPath)
+			require.NoError(t, err)
+			assert.Len(t, config.Universes, tc.ExpectedUniverses)
+			assert.Len(t, config.DeviceNames, tc.ExpectedDevices)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Interactive",
+
+		Input: strings.Join([]string{
+			"1",    // Monitor.
+			"60",   // Top.
+			"many", // Invalid right.
+			"30",   // Right.
+			"60",   // Bottom.
+			"30",   // Left.
+			"",     // Default order.
+			"",     // Default depth.
+			"0",    // Net.
+			"3",    // Sub-net.
+			"",     // Default address.
+			"2.0.0.7",
+		}, "\n"),
+
+		ExpectedOutput: []string{
+			"monitor 1: 2560x1440 at (1920,0)",
+			"sampled depth in pixels [144]: ",
+			"please enter a number between 0 and 512",
+			"screentoartnet run -config ",
+		},
+		ExpectedSettings: ambilight.Settings{
+			Dst:    stringPointer("2.0.0.7"),
+			Screen: intPointer(1),
+		},
+		ExpectedUniverses: 2,
+		ExpectedDevices:   180,
+	})
+	validate(t, &testCase{
+		Name: "Flags",
+
+		Answers: initAnswers{
+			LEDs:  stringPointer("10, 0, 10, 0"),
+			Order: stringPointer("RGBW"),
+		},
+
+		ExpectedSettings: ambilight.Settings{
+			Dst:    stringPointer("2.0.0.1"),
+			Screen: intPointer(0),
+		},
+		ExpectedUniverses: 1,
+		ExpectedDevices:   20,
+	})
+	validate(t, &testCase{
+		Name: "Invalid Flag",
+
+		Answers: initAnswers{
+			Net: intPointer(128),
+		},
+		Input: "0\n10\n10\n10\n10\n",
+
+		Error: "invalid ArtNet net, expected a number between 0 and 127: 128",
+	})
+	validate(t, &testCase{
+		Name: "Invalid LED Counts",
+
+		Answers: initAnswers{
+			LEDs: stringPointer("10,10"),
+		},
+
+		Error: "invalid LED counts, expected top,right,bottom,left: 10,10",
+	})
+	validate(t, &testCase{
+		Name: "No LEDs",
+
+		Error: "layout has no LEDs",
+	})
+}
+
+func stringPointer(s string) *string {
+	return &s
+}
+
+func intPointer(i int) *int {
+	return &i
+}
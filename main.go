@@ -1,129 +1,439 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/bauersimon/ScreenToArtNet/dmx"
 
 	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/api"
 	"github.com/bauersimon/ScreenToArtNet/capture"
+	"github.com/bauersimon/ScreenToArtNet/metrics"
+	"github.com/bauersimon/ScreenToArtNet/mqtt"
+	"github.com/bauersimon/ScreenToArtNet/web"
 )
 
+// logger holds the logger of the tool.
+var logger = slog.Default()
+
+// newLogger returns a logger writing to the given writer with the given level and format.
+func newLogger(w io.Writer, level string, format string) (*slog.Logger, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("unknown log level: %s", level)
+	}
+
+	options := &slog.HandlerOptions{
+		Level: l,
+	}
+
+	switch format {
+	case "text":
+		return slog.New(slog.NewTextHandler(w, options)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, options)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format: %s", format)
+}
+
+func readConfig() (*ambilight.Configuration, error) {
+	config, err := ambilight.ReadConfig(args.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	devices := 0
+	for _, u := range config.Universes {
+		devices += len(u.Devices)
+	}
+	logger.Info("configuration loaded", "path", args.Config, "areas", len(config.Areas), "universes", len(config.Universes), "devices", devices)
+	for _, warning := range config.Warnings {
+		logger.Warn("configuration warning", "warning", warning)
+	}
+
+	return config, nil
+}
+
+// windowFinder connects the window finder shared by the screens with the first window target.
+type windowFinder struct {
+	// lock guards the fields below.
+	lock sync.Mutex
+
+	// finder holds the connected window finder.
+	finder capture.WindowFinder
+}
+
+// get returns the window finder, connecting it on the first call.
+func (w *windowFinder) get() (capture.WindowFinder, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+
+	if w.finder == nil {
+		finder, err := capture.NewWindowFinder()
+		if err != nil {
+			return nil, err
+		}
+		w.finder = finder
+	}
+
+	return w.finder, nil
+}
+
+// newScreen returns the screen of the configuration, connecting the window finder if the configuration targets a window.
+func newScreen(config *ambilight.Configuration, windows *windowFinder) (*capture.Screen, error) {
+	var finder capture.WindowFinder
+	if config.Window != nil {
+		var err error
+		finder, err = windows.get()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return capture.NewScreen(config.Areas, captureConfig(config, finder))
+}
+
+// captureConfig returns the capture configuration of the arguments and the configuration, locating its window with the given finder.
+func captureConfig(config *ambilight.Configuration, finder capture.WindowFinder) capture.CaptureConfig {
+	return capture.CaptureConfig{
+		Spacing:   args.Spacing,
+		Threshold: args.Threshold,
+		Reducer:   capture.Reducer(args.Reducer),
+		Areas:     config.Capture,
+		Monitor:   args.Screen,
+		Window:    config.Window,
+		Finder:    finder,
+		Logger:    logger,
+	}
+}
+
+// controller extends an ambilight by reloading its configuration.
+type controller struct {
+	*ambilight.Ambilight
+
+	// windows holds the window finder of the screens.
+	windows *windowFinder
+	// reloaded holds the functions called with a reloaded configuration and its screen.
+	reloaded []func(config *ambilight.Configuration, screen *capture.Screen) error
+}
+
+// Reload reads the configuration again and applies it with the next update.
+// Errors of the functions following the reloaded configuration are reported after all of them ran.
+func (c *controller) Reload() error {
+	config, err := readConfig()
+	if err != nil {
+		return err
+	}
+
+	// Validate the capture before anything is applied, so an invalid configuration leaves everything untouched.
+	s, err := newScreen(config, c.windows)
+	if err != nil {
+		return err
+	}
+	c.Reconfigure(s, config)
+	logger.Info("configuration reloaded", "path", args.Config)
+
+	// The ambilight already follows the configuration, so a failing function does not keep the others from it.
+	var errs []error
+	for _, f := range c.reloaded {
+		if err := f(config, s); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
 func run() error {
-	areas, universes, mapping, err := ambilight.ReadConfig(*args.Config)
+	if err := capture.Reducer(args.Reducer).Verify(); err != nil {
+		return err
+	}
+
+	config, err := readConfig()
 	if err != nil {
 		return err
 	}
 
-	s := capture.NewScreen(
-		areas,
-		capture.CaptureConfig{
-			Spacing:   *args.Spacing,
-			Threshold: *args.Threshold,
-			Monitor:   *args.Screen,
-		},
-	)
+	windows := &windowFinder{}
+	s, err := newScreen(config, windows)
+	if err != nil {
+		return err
+	}
 
 	c, err := dmx.NewArtNetController(
-		*args.Src,
-		*args.Dst,
+		args.Src,
+		args.Dst,
+		logger,
 	)
 	if err != nil {
 		return err
 	}
 
-	a := &ambilight.Ambilight{
-		Controller: c,
-		Screen:     s,
-		Universes:  universes,
-		Mappings:   mapping,
-		Config: ambilight.AmbilightConfiguration{
-			Sleep: *args.Pause,
-		},
+	// Data for the dynamic performance display.
+	ctrl := &controller{
+		windows: windows,
+	}
+	lastPrint := time.Now()
+
+	options := []ambilight.Option{
+		ambilight.WithSleep(time.Duration(args.Pause) * time.Millisecond),
+		ambilight.WithLogger(logger),
+		ambilight.WithFrameHandler(func(frame *ambilight.Frame) {
+			// We will perform roughly one print every 5 seconds.
+			if frame.Time.Sub(lastPrint) < 5*time.Second {
+				return
+			}
+			lastPrint = frame.Time
+
+			logger.Info("performance", "updates_per_sec", fmt.Sprintf("%.2f", ctrl.Stats().Rate))
+		}),
+	}
+
+	if args.Metrics != "" {
+		m := metrics.New(config.AreaNames)
+		options = append(options, ambilight.WithFrameHandler(m.Observe))
+		ctrl.reloaded = append(ctrl.reloaded, func(config *ambilight.Configuration, _ *capture.Screen) error {
+			m.SetAreaNames(config.AreaNames)
+
+			return nil
+		})
+		if err := serve(args.Metrics, "/metrics", m); err != nil {
+			return err
+		}
+	}
+
+	if args.Web != "" {
+		w, err := web.NewServer(args.Config, config, s, logger)
+		if err != nil {
+			return err
+		}
+		options = append(options, ambilight.WithFrameHandler(w.Observe))
+		ctrl.reloaded = append(ctrl.reloaded, func(config *ambilight.Configuration, screen *capture.Screen) error {
+			return w.SetConfiguration(config, screen)
+		})
+		if err := serve(args.Web, "/", w); err != nil {
+			return err
+		}
+	}
+
+	var bridge *mqtt.Bridge
+	if args.MQTT != "" {
+		mqttConfig := mqtt.Config{
+			Prefix: args.MQTTPrefix,
+			Fade:   time.Duration(args.MQTTFade) * time.Millisecond,
+		}
+		client, err := mqtt.Connect(args.MQTT, mqttClientID(args.MQTTPrefix), mqttConfig)
+		if err != nil {
+			return err
+		}
+		bridge = mqtt.NewBridge(client, ctrl, config.AreaNames, mqttConfig, logger)
+		options = append(options, ambilight.WithFrameHandler(bridge.Observe))
+		ctrl.reloaded = append(ctrl.reloaded, func(config *ambilight.Configuration, _ *capture.Screen) error {
+			return bridge.SetAreaNames(config.AreaNames)
+		})
+	}
+
+	ctrl.Ambilight = ambilight.New(s, c, config, options...)
+
+	if args.API != "" {
+		if err := serve(args.API, "/api/", http.StripPrefix("/api", api.NewHandler(ctrl, logger))); err != nil {
+			return err
+		}
+	}
+
+	if err := ctrl.Start(); err != nil {
+		return err
+	}
+	if bridge != nil {
+		if err := bridge.Start(); err != nil {
+			ctrl.Stop()
+
+			return err
+		}
 	}
 
-	return a.Go()
+	return ctrl.Wait()
+}
+
+// mqttClientID returns a client identifier unique per host.
+func mqttClientID(prefix string) string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("screentoartnet-%s-%s", prefix, hostname)
+}
+
+// serve binds the address and serves the handler at the given pattern in the background.
+func serve(address string, pattern string, handler http.Handler) error {
+	mux := http.NewServeMux()
+	mux.Handle(pattern, handler)
+
+	// Bind before returning so an unusable address fails the start.
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		logger.Info("serving HTTP", "address", address, "path", pattern)
+		if err := http.Serve(listener, mux); err != nil {
+			logger.Error("serving HTTP failed", "address", address, "error", err)
+		}
+	}()
+
+	return nil
 }
 
 func preview() error {
-	areas, _, _, err := ambilight.ReadConfig(*args.Config)
+	config, err := readConfig()
 	if err != nil {
 		return err
 	}
 
-	s := capture.NewScreen(
-		areas,
-		capture.CaptureConfig{
-			Monitor: *args.Screen,
-		},
-	)
+	s, err := newScreen(config, &windowFinder{})
+	if err != nil {
+		return err
+	}
 
 	cwd, err := os.Getwd()
 	if err != nil {
 		return err
 	}
 
-	return s.SavePreview(filepath.Join(cwd, "preview"))
+	return s.SavePreview(filepath.Join(cwd, "preview"), config.AreaNames)
 }
 
-var args = struct {
-	Mode      *string
-	Src       *string
-	Dst       *string
-	Pause     *int
-	Screen    *int
-	Spacing   *int
-	Threshold *int
-	Config    *string
-}{
-	flag.String("mode", "run", "tool mode {run|preview}"),
-	flag.String("src", "", "artnet source"),
-	flag.String("dst", "", "artnet destination"),
-	flag.Int("pause", 0, "pause time in ms"),
-	flag.Int("screen", 0, "screen identifier"),
-	flag.Int("spacing", 1, "spacing of pixels for averaging"),
-	flag.Int("threshold", 0, "threshold of color (0<255)"),
-	flag.String("config", "config.json", "config file"),
+// args holds the flag values of the command line.
+var args struct {
+	Src        string
+	Dst        string
+	Pause      int
+	Screen     int
+	Spacing    int
+	Threshold  int
+	Reducer    string
+	Config     string
+	LogLevel   string
+	LogFormat  string
+	Metrics    string
+	Web        string
+	API        string
+	MQTT       string
+	MQTTPrefix string
+	MQTTFade   int
+	Timeout    int
+	LEDs       string
+	Order      string
+	Depth      int
+	Net        int
+	SubNet     int
+	Address    int
 }
 
 func main() {
-	if len(os.Args) == 1 {
-		flag.PrintDefaults()
-		return
-	}
-	flag.Parse()
+	os.Exit(execute(os.Args[1:], os.Stderr, os.Getenv, setupProcess))
+}
+
+// setupProcess makes the logger the default of the process and stops the process on an interrupt.
+func setupProcess(logger *slog.Logger) {
+	slog.SetDefault(logger)
 
 	// Make sure we clean everything up.
-	abort := make(chan os.Signal)
+	abort := make(chan os.Signal, 1)
 	signal.Notify(abort, os.Interrupt, syscall.SIGTERM)
 	go func() {
 		s := <-abort
-		fmt.Printf("\r%v received, stopping...\n", s)
-		os.Exit(0)
+		logger.Info("stopping", "signal", s.String())
+		os.Exit(exitSuccess)
 	}()
+}
 
-	switch *args.Mode {
-	case "run":
-		err := run()
-		if err != nil {
-			crash(err)
-		}
-	case "preview":
-		err := preview()
+// execute runs the command of the given arguments and returns the exit code.
+// The usage and the log are written to stderr, setup is called with the logger before the command runs.
+func execute(arguments []string, stderr io.Writer, getenv func(string) string, setup func(logger *slog.Logger)) int {
+	cmd, flags, err := parseArguments(arguments, stderr)
+	if err == flag.ErrHelp {
+		return exitSuccess
+	} else if err != nil {
+		return exitUsage
+	}
+
+	if flags.Lookup("config") != nil {
+		args.Config = configPath(args.Config, flagSet(flags, "config"), getenv(configEnv))
+	}
+
+	settings := &ambilight.Settings{}
+	if cmd.Settings {
+		s, err := ambilight.ReadSettings(args.Config)
 		if err != nil {
-			crash(err)
+			fmt.Fprintf(stderr, "invalid configuration: %v\n", err)
+
+			return exitFailure
 		}
-	default:
-		fmt.Printf("unknown mode: %s", *args.Mode)
+		settings = s
+	}
+	sources, err := applySettings(flags, settings, getenv)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitUsage
+	}
+
+	l, err := newLogger(stderr, args.LogLevel, args.LogFormat)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+
+		return exitUsage
+	}
+	logger = l
+	setup(logger)
+	if cmd.Settings {
+		logSettings(logger, flags, sources)
+	}
+
+	if err := cmd.Run(flags, sources); err != nil {
+		logger.Error("encountered error", "error", err)
+
+		return exitFailure
 	}
+
+	return exitSuccess
+}
+
+// configEnv holds the environment variable overriding the default config file.
+const configEnv = "SCREENTOARTNET_CONFIG"
+
+// configPath returns the config file to use, the environment variable overrides the default but not an explicit flag.
+func configPath(flagValue string, explicit bool, envValue string) string {
+	if explicit || envValue == "" {
+		return flagValue
+	}
+
+	return envValue
 }
 
-func crash(err error) {
-	fmt.Printf("encountered error:\n%s\n", err.Error())
-	os.Exit(-1)
+// flagSet returns if the flag with the given name was set on the command line.
+func flagSet(flags *flag.FlagSet, name string) (set bool) {
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+
+	return set
 }
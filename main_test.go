@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Level  string
+		Format string
+
+		Output string
+		Error  string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			l, err := newLogger(&buffer, tc.Level, tc.Format)
+			if tc.Error != "" {
+				assert.EqualError(t, err, tc.Error)
+
+				return
+			}
+			assert.NoError(t, err)
+
+			l.Debug("hidden")
+			l.Warn("shown", "key", "value")
+			assert.Contains(t, buffer.String(), tc.Output)
+			assert.NotContains(t, buffer.String(), "hidden")
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Text",
+
+		Level:  "warn",
+		Format: "text",
+
+		Output: "level=WARN msg=shown key=value",
+	})
+	validate(t, &testCase{
+		Name: "JSON",
+
+		Level:  "INFO",
+		Format: "json",
+
+		Output: `"level":"WARN","msg":"shown","key":"value"}`,
+	})
+	validate(t, &testCase{
+		Name: "Unknown Level",
+
+		Level:  "verbose",
+		Format: "text",
+
+		Error: "unknown log level: verbose",
+	})
+	validate(t, &testCase{
+		Name: "Unknown Format",
+
+		Level:  "info",
+		Format: "xml",
+
+		Error: "unknown log format: xml",
+	})
+}
+
+func TestConfigPath(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Flag     string
+		Explicit bool
+		Env      string
+
+		Expected string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, configPath(tc.Flag, tc.Explicit, tc.Env))
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Default",
+
+		Flag: "config.json",
+
+		Expected: "config.json",
+	})
+	validate(t, &testCase{
+		Name: "Environment",
+
+		Flag: "config.json",
+		Env:  "/etc/screentoartnet/living-room.yaml",
+
+		Expected: "/etc/screentoartnet/living-room.yaml",
+	})
+	validate(t, &testCase{
+		Name: "Explicit Flag",
+
+		Flag:     "kitchen.json",
+		Explicit: true,
+		Env:      "/etc/screentoartnet/living-room.yaml",
+
+		Expected: "kitchen.json",
+	})
+}
+
+func TestServe(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer listener.Close()
+
+	// An address in use fails the start instead of only being logged.
+	assert.Error(t, serve(listener.Addr().String(), "/", http.NotFoundHandler()))
+}
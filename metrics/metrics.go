@@ -0,0 +1,224 @@
+package metrics
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+)
+
+// namespace prefixes all metric names.
+const namespace = "screentoartnet"
+
+// durationBuckets holds the upper bounds of the duration histogram buckets in seconds.
+var durationBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1}
+
+// histogram holds a cumulative Prometheus histogram.
+type histogram struct {
+	// counts holds the number of observations per bucket, the last bucket being +Inf.
+	counts []uint64
+	// sum holds the sum of all observations.
+	sum float64
+	// count holds the number of observations.
+	count uint64
+}
+
+func newHistogram() *histogram {
+	return &histogram{
+		counts: make([]uint64, len(durationBuckets)+1),
+	}
+}
+
+func (h *histogram) observe(d time.Duration) {
+	v := d.Seconds()
+
+	i := sort.SearchFloat64s(durationBuckets, v)
+	h.counts[i]++
+	h.sum += v
+	h.count++
+}
+
+// universe identifies a universe by its port address.
+type universe struct {
+	net    uint8
+	subNet uint8
+}
+
+// Metrics collects the metrics of an ambilight and exposes them in the Prometheus text format.
+type Metrics struct {
+	// lock guards the fields below.
+	lock sync.Mutex
+
+	// areaNames holds the names of the screen areas.
+	areaNames map[*image.Rectangle]string
+
+	framesCaptured uint64
+	framesDropped  uint64
+	sendErrors     uint64
+	framesSent     map[universe]uint64
+
+	captureDuration *histogram
+	averageDuration *histogram
+	sendDuration    *histogram
+
+	// colors holds the current color per area name.
+	colors map[string][3]uint16
+}
+
+// New returns new metrics labeling the screen areas with the given names.
+func New(areaNames map[*image.Rectangle]string) *Metrics {
+	return &Metrics{
+		areaNames: areaNames,
+
+		framesSent: map[universe]uint64{},
+
+		captureDuration: newHistogram(),
+		averageDuration: newHistogram(),
+		sendDuration:    newHistogram(),
+
+		colors: map[string][3]uint16{},
+	}
+}
+
+// SetAreaNames replaces the names of the screen areas, e.g. after a reload of the configuration.
+// The colors of the previous areas are dropped.
+func (m *Metrics) SetAreaNames(areaNames map[*image.Rectangle]string) {
+	m.lock.Lock()
+	m.areaNames = areaNames
+	m.colors = map[string][3]uint16{}
+	m.lock.Unlock()
+}
+
+// Observe records an ambilight frame, suitable for ambilight.WithFrameHandler.
+func (m *Metrics) Observe(frame *ambilight.Frame) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if frame.Dropped() {
+		m.framesDropped++
+	}
+	m.sendErrors += uint64(frame.SendErrors)
+	for _, u := range frame.Sent {
+		m.framesSent[universe{net: u.Net, subNet: u.SubNet}]++
+	}
+	m.sendDuration.observe(frame.Send)
+
+	// Frames held while paused or showing a preset are sent without capturing the screen.
+	if !frame.Captured {
+		return
+	}
+	m.framesCaptured++
+	m.captureDuration.observe(frame.Capture)
+	if frame.Average > 0 {
+		m.averageDuration.observe(frame.Average)
+	}
+
+	for area, c := range frame.Colors {
+		name, ok := m.areaNames[area]
+		if !ok {
+			name = area.String()
+		}
+		m.colors[name] = [3]uint16{c.R, c.G, c.B}
+	}
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	if err := m.Write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// Write writes the metrics in the Prometheus text format.
+func (m *Metrics) Write(w io.Writer) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var b strings.Builder
+
+	writeHeader(&b, "frames_captured_total", "counter", "Number of captured frames.")
+	writeSample(&b, "frames_captured_total", "", float64(m.framesCaptured))
+
+	writeHeader(&b, "frames_dropped_total", "counter", "Number of frames that did not reach every universe.")
+	writeSample(&b, "frames_dropped_total", "", float64(m.framesDropped))
+
+	writeHeader(&b, "send_errors_total", "counter", "Number of failed universe sends.")
+	writeSample(&b, "send_errors_total", "", float64(m.sendErrors))
+
+	writeHeader(&b, "frames_sent_total", "counter", "Number of frames sent per universe.")
+	var universes []universe
+	for u := range m.framesSent {
+		universes = append(universes, u)
+	}
+	sort.Slice(universes, func(i, j int) bool {
+		if universes[i].net != universes[j].net {
+			return universes[i].net < universes[j].net
+		}
+
+		return universes[i].subNet < universes[j].subNet
+	})
+	for _, u := range universes {
+		writeSample(&b, "frames_sent_total", fmt.Sprintf(`net="%d",subnet="%d"`, u.net, u.subNet), float64(m.framesSent[u]))
+	}
+
+	writeHistogram(&b, "capture_duration_seconds", "Time spent capturing the screen.", m.captureDuration)
+	writeHistogram(&b, "average_duration_seconds", "Time spent averaging the screen areas.", m.averageDuration)
+	writeHistogram(&b, "send_duration_seconds", "Time spent sending the DMX frames.", m.sendDuration)
+
+	writeHeader(&b, "area_color", "gauge", "Current 16-bit color channel value per screen area.")
+	var names []string
+	for name := range m.colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		c := m.colors[name]
+		for i, channel := range []string{"red", "green", "blue"} {
+			writeSample(&b, "area_color", fmt.Sprintf(`area="%s",channel="%s"`, escapeLabel(name), channel), float64(c[i]))
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+
+	return err
+}
+
+func writeHeader(b *strings.Builder, name string, kind string, help string) {
+	fmt.Fprintf(b, "# HELP %s_%s %s\n", namespace, name, help)
+	fmt.Fprintf(b, "# TYPE %s_%s %s\n", namespace, name, kind)
+}
+
+func writeSample(b *strings.Builder, name string, labels string, value float64) {
+	if labels != "" {
+		labels = "{" + labels + "}"
+	}
+
+	fmt.Fprintf(b, "%s_%s%s %s\n", namespace, name, labels, strconv.FormatFloat(value, 'g', -1, 64))
+}
+
+func writeHistogram(b *strings.Builder, name string, help string, h *histogram) {
+	writeHeader(b, name, "histogram", help)
+
+	var cumulative uint64
+	for i, bound := range durationBuckets {
+		cumulative += h.counts[i]
+		writeSample(b, name+"_bucket", fmt.Sprintf(`le="%s"`, strconv.FormatFloat(bound, 'g', -1, 64)), float64(cumulative))
+	}
+	cumulative += h.counts[len(durationBuckets)]
+	writeSample(b, name+"_bucket", `le="+Inf"`, float64(cumulative))
+	writeSample(b, name+"_sum", "", h.sum)
+	writeSample(b, name+"_count", "", float64(h.count))
+}
+
+func escapeLabel(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"image"
+	"image/color"
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetrics(t *testing.T) {
+	area := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	unnamed := &image.Rectangle{Max: image.Point{X: 5, Y: 5}}
+	u1 := &dmx.Universe{Net: 0, SubNet: 1}
+	u2 := &dmx.Universe{Net: 1, SubNet: 0}
+
+	m := New(map[*image.Rectangle]string{
+		area: `left "edge"`,
+	})
+
+	m.Observe(&ambilight.Frame{
+		Colors: map[*image.Rectangle]color.RGBA64{
+			area: color.RGBA64{R: 1, G: 2, B: 3, A: 0xffff},
+		},
+		Captured: true,
+		Sent:     []*dmx.Universe{u1, u2},
+		Capture:  2 * time.Millisecond,
+		Average:  500 * time.Microsecond,
+		Send:     2 * time.Second,
+	})
+	m.Observe(&ambilight.Frame{
+		Colors: map[*image.Rectangle]color.RGBA64{
+			area:    color.RGBA64{R: 4, G: 5, B: 6, A: 0xffff},
+			unnamed: color.RGBA64{A: 0xffff},
+		},
+		Captured:   true,
+		Sent:       []*dmx.Universe{u1},
+		SendErrors: 1,
+		Capture:    20 * time.Millisecond,
+	})
+	// A frame held while paused is sent without capturing the screen.
+	m.Observe(&ambilight.Frame{
+		Sent: []*dmx.Universe{u1},
+		Send: time.Millisecond,
+	})
+
+	server := httptest.NewServer(m)
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL)
+	assert.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", response.Header.Get("Content-Type"))
+
+	data, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	body := string(data)
+
+	for _, expected := range []string{
+		"# TYPE screentoartnet_frames_captured_total counter\nscreentoartnet_frames_captured_total 2\n",
+		"screentoartnet_frames_dropped_total 1\n",
+		"screentoartnet_send_errors_total 1\n",
+		"screentoartnet_frames_sent_total{net=\"0\",subnet=\"1\"} 3\nscreentoartnet_frames_sent_total{net=\"1\",subnet=\"0\"} 1\n",
+		"# TYPE screentoartnet_capture_duration_seconds histogram\n",
+		"screentoartnet_capture_duration_seconds_bucket{le=\"0.001\"} 0\n",
+		"screentoartnet_capture_duration_seconds_bucket{le=\"0.0025\"} 1\n",
+		"screentoartnet_capture_duration_seconds_bucket{le=\"0.025\"} 2\n",
+		"screentoartnet_capture_duration_seconds_bucket{le=\"+Inf\"} 2\n",
+		"screentoartnet_capture_duration_seconds_sum 0.022\n",
+		"screentoartnet_capture_duration_seconds_count 2\n",
+		"screentoartnet_average_duration_seconds_bucket{le=\"0.001\"} 1\n",
+		"screentoartnet_average_duration_seconds_count 1\n",
+		"screentoartnet_send_duration_seconds_bucket{le=\"1\"} 2\n",
+		"screentoartnet_send_duration_seconds_bucket{le=\"+Inf\"} 3\n",
+		"screentoartnet_area_color{area=\"(0,0)-(5,5)\",channel=\"red\"} 0\n",
+		"screentoartnet_area_color{area=\"left \\\"edge\\\"\",channel=\"red\"} 4\n",
+		"screentoartnet_area_color{area=\"left \\\"edge\\\"\",channel=\"blue\"} 6\n",
+	} {
+		assert.Contains(t, body, expected)
+	}
+}
+
+func TestMetricsSetAreaNames(t *testing.T) {
+	area := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+
+	m := New(map[*image.Rectangle]string{
+		area: "left",
+	})
+	m.Observe(&ambilight.Frame{
+		Colors: map[*image.Rectangle]color.RGBA64{
+			area: color.RGBA64{R: 1, G: 2, B: 3, A: 0xffff},
+		},
+		Captured: true,
+	})
+
+	var b strings.Builder
+	assert.NoError(t, m.Write(&b))
+	assert.Contains(t, b.String(), "screentoartnet_area_color{area=\"left\",channel=\"red\"} 1\n")
+
+	// The colors of removed areas are not reported anymore.
+	m.SetAreaNames(map[*image.Rectangle]string{
+		{Max: image.Point{X: 5, Y: 5}}: "right",
+	})
+	b.Reset()
+	assert.NoError(t, m.Write(&b))
+	assert.NotContains(t, b.String(), "area=\"left\"")
+}
@@ -0,0 +1,496 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+)
+
+// Client publishes and subscribes MQTT messages, e.g. a paho client returned by Connect.
+type Client interface {
+	// Publish publishes the payload to the given topic.
+	Publish(topic string, payload []byte, retained bool) error
+	// Subscribe calls the handler with every message published to the given topic.
+	Subscribe(topic string, handler func(topic string, payload []byte)) error
+}
+
+// reconnector is a client reporting that it connected to the broker again, e.g. a paho client returned by Connect.
+// It has to subscribe the topics again itself.
+type reconnector interface {
+	// OnReconnect registers a function called after the connection to the broker was established again.
+	OnReconnect(f func())
+}
+
+// Controller controls a running ambilight, e.g. an *ambilight.Ambilight.
+type Controller interface {
+	// Stats returns the current running statistics.
+	Stats() ambilight.Stats
+	// Pause suspends the updates until Resume is called.
+	Pause()
+	// Resume continues the updates after a Pause.
+	Resume()
+	// SetBlackout sets whether all channels are sent as zero.
+	SetBlackout(blackout bool)
+	// SetMaster sets the master intensity between 0 and 1.
+	SetMaster(master float64) error
+	// SetMode crossfades over the given duration to the mode, either ambilight.ModeAmbilight or the name of a preset.
+	SetMode(mode string, fade time.Duration) error
+	// Modes returns all modes.
+	Modes() []string
+}
+
+// ModeBlackout is the mode sending all channels as zero, published and accepted besides the modes of the ambilight.
+const ModeBlackout = "blackout"
+
+// Payloads of the enable topics.
+const (
+	payloadOn  = "ON"
+	payloadOff = "OFF"
+)
+
+// Config holds the configuration of the MQTT bridge.
+type Config struct {
+	// Prefix holds the prefix of all topics, defaults to "screentoartnet".
+	Prefix string
+	// DiscoveryPrefix holds the Home Assistant discovery prefix, defaults to "homeassistant".
+	// Discovery is disabled if set to "-".
+	DiscoveryPrefix string
+	// NodeID holds the identifier of this instance for Home Assistant, defaults to the prefix.
+	NodeID string
+	// Interval holds the minimal time between two publications of the area colors, defaults to one second.
+	Interval time.Duration
+	// Fade holds the crossfade time of mode commands.
+	Fade time.Duration
+}
+
+// AvailabilityTopic returns the topic the availability of the bridge is published to.
+func (c Config) AvailabilityTopic() string {
+	return c.withDefaults().Prefix + "/status"
+}
+
+func (c Config) withDefaults() Config {
+	if c.Prefix == "" {
+		c.Prefix = "screentoartnet"
+	}
+	if c.DiscoveryPrefix == "" {
+		c.DiscoveryPrefix = "homeassistant"
+	}
+	if c.NodeID == "" {
+		c.NodeID = sanitize(c.Prefix)
+	}
+	if c.Interval == 0 {
+		c.Interval = time.Second
+	}
+
+	return c
+}
+
+// Bridge publishes the state and area colors of an ambilight and controls it with commands received over MQTT.
+type Bridge struct {
+	// client holds the MQTT client.
+	client Client
+	// controller holds the controlled ambilight.
+	controller Controller
+	// config holds the configuration of the bridge.
+	config Config
+	// logger holds the logger of the bridge.
+	logger *slog.Logger
+	// frames holds the observed frame waiting to be published, further frames are dropped meanwhile.
+	frames chan *ambilight.Frame
+	// started starts publishing the frames once.
+	started sync.Once
+
+	// lock guards the fields below.
+	lock sync.Mutex
+	// areaNames holds the names of the screen areas.
+	areaNames map[*image.Rectangle]string
+	// lastColors holds the time the area colors were published last.
+	lastColors time.Time
+	// lastState holds the state published last.
+	lastState state
+	// discovered holds the discovery topics published last.
+	discovered map[string]bool
+}
+
+// state holds the published state of the ambilight.
+type state struct {
+	enabled    bool
+	brightness int
+	mode       string
+}
+
+// NewBridge returns a new bridge between the MQTT client and the ambilight.
+func NewBridge(client Client, controller Controller, areaNames map[*image.Rectangle]string, config Config, logger *slog.Logger) *Bridge {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	return &Bridge{
+		client:     client,
+		controller: controller,
+		config:     config.withDefaults(),
+		logger:     logger,
+		frames:     make(chan *ambilight.Frame, 1),
+
+		areaNames: areaNames,
+	}
+}
+
+func (b *Bridge) topic(parts ...string) string {
+	return b.config.Prefix + "/" + strings.Join(parts, "/")
+}
+
+// Start subscribes to the command topics and publishes the availability, the discovery payloads and the current state.
+func (b *Bridge) Start() error {
+	commands := map[string]func(payload string) error{
+		b.topic("enable", "set"):     b.enable,
+		b.topic("brightness", "set"): b.brightness,
+		b.topic("mode", "set"):       b.mode,
+		b.topic("scene", "set"):      b.scene,
+	}
+	for topic, command := range commands {
+		command := command
+		if err := b.client.Subscribe(topic, func(topic string, payload []byte) {
+			if err := command(strings.TrimSpace(string(payload))); err != nil {
+				b.logger.Warn("invalid MQTT command", "topic", topic, "payload", string(payload), "error", err)
+			} else {
+				b.logger.Info("MQTT command", "topic", topic, "payload", string(payload))
+			}
+			b.publishState(true)
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := b.publishAll(); err != nil {
+		return err
+	}
+	if r, ok := b.client.(reconnector); ok {
+		r.OnReconnect(b.reconnected)
+	}
+	b.started.Do(func() {
+		go func() {
+			for frame := range b.frames {
+				b.publishFrame(frame)
+			}
+		}()
+	})
+
+	b.logger.Info("MQTT bridge started", "prefix", b.config.Prefix)
+
+	return nil
+}
+
+// publishAll publishes the availability, the discovery payloads and the current state.
+func (b *Bridge) publishAll() error {
+	if err := b.client.Publish(b.config.AvailabilityTopic(), []byte("online"), true); err != nil {
+		return err
+	}
+	if err := b.publishDiscovery(); err != nil {
+		return err
+	}
+	b.publishState(true)
+
+	return nil
+}
+
+// reconnected publishes everything again, since the broker published "offline" as the last will of the lost connection.
+func (b *Bridge) reconnected() {
+	if err := b.publishAll(); err != nil {
+		b.logger.Error("MQTT publishing failed", "error", err)
+
+		return
+	}
+
+	b.logger.Info("MQTT bridge reconnected")
+}
+
+// SetAreaNames replaces the names of the screen areas, e.g. after a reload of the configuration, and publishes the discovery payloads again with the current modes.
+func (b *Bridge) SetAreaNames(areaNames map[*image.Rectangle]string) error {
+	b.lock.Lock()
+	b.areaNames = areaNames
+	b.lock.Unlock()
+
+	return b.publishDiscovery()
+}
+
+// Observe publishes the state and, at most once per interval, the area colors of the frame in the background, suitable for ambilight.WithFrameHandler.
+// Frames are dropped while the previous frame is still being published, so a slow broker does not hold up the updates.
+func (b *Bridge) Observe(frame *ambilight.Frame) {
+	select {
+	case b.frames <- frame:
+	default:
+	}
+}
+
+// publishFrame publishes the state and, at most once per interval, the area colors of the frame.
+func (b *Bridge) publishFrame(frame *ambilight.Frame) {
+	b.publishState(false)
+
+	b.lock.Lock()
+	if frame.Time.Sub(b.lastColors) < b.config.Interval {
+		b.lock.Unlock()
+
+		return
+	}
+	b.lastColors = frame.Time
+
+	colors := map[string]string{}
+	for area, c := range frame.Colors {
+		if name, ok := b.areaNames[area]; ok {
+			colors[name] = fmt.Sprintf("%d,%d,%d", c.R>>8, c.G>>8, c.B>>8)
+		}
+	}
+	b.lock.Unlock()
+
+	for name, c := range colors {
+		if err := b.client.Publish(b.topic("areas", sanitize(name), "color"), []byte(c), false); err != nil {
+			b.logger.Error("MQTT publishing failed", "area", name, "error", err)
+
+			return
+		}
+	}
+}
+
+// enable turns the fixtures off with a blackout of the paused ambilight, and on again.
+func (b *Bridge) enable(payload string) error {
+	switch payload {
+	case payloadOn:
+		b.controller.SetBlackout(false)
+		b.controller.Resume()
+	case payloadOff:
+		b.controller.SetBlackout(true)
+		b.controller.Pause()
+	default:
+		return fmt.Errorf("unknown enable payload: %s", payload)
+	}
+
+	return nil
+}
+
+func (b *Bridge) brightness(payload string) error {
+	percent, err := strconv.ParseFloat(payload, 64)
+	if err != nil {
+		return fmt.Errorf("invalid brightness: %s", payload)
+	}
+
+	return b.controller.SetMaster(percent / 100)
+}
+
+func (b *Bridge) mode(payload string) error {
+	if payload == ModeBlackout {
+		b.controller.SetBlackout(true)
+
+		return nil
+	}
+
+	if err := b.controller.SetMode(payload, b.config.Fade); err != nil {
+		return err
+	}
+	b.controller.SetBlackout(false)
+
+	return nil
+}
+
+// scene activates the preset of the payload, e.g. from a Home Assistant scene, other modes are left to the mode topic.
+func (b *Bridge) scene(payload string) error {
+	if payload == ambilight.ModeAmbilight {
+		return fmt.Errorf("unknown scene: %s", payload)
+	}
+	for _, mode := range b.controller.Modes() {
+		if mode == payload {
+			return b.mode(payload)
+		}
+	}
+
+	return fmt.Errorf("unknown scene: %s", payload)
+}
+
+// publishState publishes the state of the ambilight if it changed or if forced.
+func (b *Bridge) publishState(force bool) {
+	stats := b.controller.Stats()
+	current := state{
+		enabled:    stats.Running && !stats.Paused,
+		brightness: int(stats.MasterTarget*100 + 0.5),
+		mode:       stats.Mode,
+	}
+	if stats.Blackout {
+		current.mode = ModeBlackout
+	}
+
+	b.lock.Lock()
+	if !force && current == b.lastState {
+		b.lock.Unlock()
+
+		return
+	}
+	b.lastState = current
+	b.lock.Unlock()
+
+	enabled := payloadOff
+	if current.enabled {
+		enabled = payloadOn
+	}
+
+	for topic, payload := range map[string]string{
+		b.topic("enable"):     enabled,
+		b.topic("brightness"): strconv.Itoa(current.brightness),
+		b.topic("mode"):       current.mode,
+	} {
+		if err := b.client.Publish(topic, []byte(payload), true); err != nil {
+			b.logger.Error("MQTT publishing failed", "topic", topic, "error", err)
+		}
+	}
+}
+
+// discoveryDevice holds the Home Assistant device all entities belong to.
+type discoveryDevice struct {
+	Identifiers []string `json:"identifiers"`
+	Name        string   `json:"name"`
+}
+
+// discoveryLight holds the Home Assistant discovery payload of the ambilight.
+type discoveryLight struct {
+	Name                   string          `json:"name"`
+	UniqueID               string          `json:"unique_id"`
+	Device                 discoveryDevice `json:"device"`
+	AvailabilityTopic      string          `json:"availability_topic"`
+	CommandTopic           string          `json:"command_topic"`
+	StateTopic             string          `json:"state_topic"`
+	BrightnessCommandTopic string          `json:"brightness_command_topic"`
+	BrightnessStateTopic   string          `json:"brightness_state_topic"`
+	BrightnessScale        int             `json:"brightness_scale"`
+	EffectCommandTopic     string          `json:"effect_command_topic"`
+	EffectStateTopic       string          `json:"effect_state_topic"`
+	EffectList             []string        `json:"effect_list"`
+}
+
+// discoverySensor holds the Home Assistant discovery payload of an area color.
+type discoverySensor struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	Device            discoveryDevice `json:"device"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	StateTopic        string          `json:"state_topic"`
+	Icon              string          `json:"icon"`
+}
+
+// discoveryScene holds the Home Assistant discovery payload of a preset.
+type discoveryScene struct {
+	Name              string          `json:"name"`
+	UniqueID          string          `json:"unique_id"`
+	Device            discoveryDevice `json:"device"`
+	AvailabilityTopic string          `json:"availability_topic"`
+	CommandTopic      string          `json:"command_topic"`
+	PayloadOn         string          `json:"payload_on"`
+}
+
+func (b *Bridge) publishDiscovery() error {
+	if b.config.DiscoveryPrefix == "-" {
+		return nil
+	}
+
+	device := discoveryDevice{
+		Identifiers: []string{b.config.NodeID},
+		Name:        "ScreenToArtNet " + b.config.NodeID,
+	}
+
+	payloads := map[string]any{
+		b.config.DiscoveryPrefix + "/light/" + b.config.NodeID + "/ambilight/config": discoveryLight{
+			Name:                   "Ambilight",
+			UniqueID:               b.config.NodeID + "_ambilight",
+			Device:                 device,
+			AvailabilityTopic:      b.config.AvailabilityTopic(),
+			CommandTopic:           b.topic("enable", "set"),
+			StateTopic:             b.topic("enable"),
+			BrightnessCommandTopic: b.topic("brightness", "set"),
+			BrightnessStateTopic:   b.topic("brightness"),
+			BrightnessScale:        100,
+			EffectCommandTopic:     b.topic("mode", "set"),
+			EffectStateTopic:       b.topic("mode"),
+			EffectList:             append(b.controller.Modes(), ModeBlackout),
+		},
+	}
+
+	for _, mode := range b.controller.Modes() {
+		if mode == ambilight.ModeAmbilight {
+			continue
+		}
+		id := sanitize(mode)
+		payloads[b.config.DiscoveryPrefix+"/scene/"+b.config.NodeID+"/"+id+"/config"] = discoveryScene{
+			Name:              "Scene " + mode,
+			UniqueID:          b.config.NodeID + "_scene_" + id,
+			Device:            device,
+			AvailabilityTopic: b.config.AvailabilityTopic(),
+			CommandTopic:      b.topic("scene", "set"),
+			PayloadOn:         mode,
+		}
+	}
+
+	b.lock.Lock()
+	for _, name := range b.areaNames {
+		id := sanitize(name)
+		payloads[b.config.DiscoveryPrefix+"/sensor/"+b.config.NodeID+"/"+id+"/config"] = discoverySensor{
+			Name:              "Area " + name,
+			UniqueID:          b.config.NodeID + "_area_" + id,
+			Device:            device,
+			AvailabilityTopic: b.config.AvailabilityTopic(),
+			StateTopic:        b.topic("areas", id, "color"),
+			Icon:              "mdi:palette",
+		}
+	}
+	var removed []string
+	for topic := range b.discovered {
+		if _, ok := payloads[topic]; !ok {
+			removed = append(removed, topic)
+		}
+	}
+	b.discovered = make(map[string]bool, len(payloads))
+	for topic := range payloads {
+		b.discovered[topic] = true
+	}
+	b.lock.Unlock()
+
+	// An empty retained payload removes the entities of areas and presets that are gone, e.g. after a reload.
+	sort.Strings(removed)
+	for _, topic := range removed {
+		if err := b.client.Publish(topic, nil, true); err != nil {
+			return err
+		}
+	}
+
+	topics := make([]string, 0, len(payloads))
+	for topic := range payloads {
+		topics = append(topics, topic)
+	}
+	sort.Strings(topics)
+
+	for _, topic := range topics {
+		data, err := json.Marshal(payloads[topic])
+		if err != nil {
+			return err
+		}
+		if err := b.client.Publish(topic, data, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// invalidTopicCharacters matches characters that are not allowed in topic levels and Home Assistant object IDs.
+var invalidTopicCharacters = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// sanitize returns the name usable as a single topic level and Home Assistant object ID.
+func sanitize(name string) string {
+	return invalidTopicCharacters.ReplaceAllString(name, "_")
+}
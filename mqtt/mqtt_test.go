@@ -0,0 +1,394 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeBroker is an in-process stand-in for an MQTT broker, delivering messages to the subscribers of exact topics.
+type fakeBroker struct {
+	lock        sync.Mutex
+	retained    map[string]string
+	published   []string
+	subscribers map[string][]func(topic string, payload []byte)
+	reconnected []func()
+
+	// gate blocks publishing until it is closed, if set.
+	gate chan struct{}
+}
+
+func newFakeBroker() *fakeBroker {
+	return &fakeBroker{
+		retained:    map[string]string{},
+		subscribers: map[string][]func(topic string, payload []byte){},
+	}
+}
+
+func (b *fakeBroker) Publish(topic string, payload []byte, retained bool) error {
+	b.lock.Lock()
+	gate := b.gate
+	b.lock.Unlock()
+	if gate != nil {
+		<-gate
+	}
+
+	b.lock.Lock()
+	b.published = append(b.published, topic+" "+string(payload))
+	if retained {
+		b.retained[topic] = string(payload)
+	}
+	subscribers := b.subscribers[topic]
+	b.lock.Unlock()
+
+	for _, s := range subscribers {
+		s(topic, payload)
+	}
+
+	return nil
+}
+
+func (b *fakeBroker) Subscribe(topic string, handler func(topic string, payload []byte)) error {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.subscribers[topic] = append(b.subscribers[topic], handler)
+
+	return nil
+}
+
+func (b *fakeBroker) OnReconnect(f func()) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.reconnected = append(b.reconnected, f)
+}
+
+// reconnect publishes the last will of the lost connection and reconnects.
+func (b *fakeBroker) reconnect(availabilityTopic string) {
+	b.lock.Lock()
+	b.retained = map[string]string{availabilityTopic: "offline"}
+	reconnected := b.reconnected
+	b.lock.Unlock()
+
+	for _, f := range reconnected {
+		f()
+	}
+}
+
+// fakeController holds the state changed by the commands.
+type fakeController struct {
+	stats ambilight.Stats
+}
+
+func (c *fakeController) Stats() ambilight.Stats {
+	return c.stats
+}
+
+func (c *fakeController) Pause() {
+	c.stats.Paused = true
+}
+
+func (c *fakeController) Resume() {
+	c.stats.Paused = false
+}
+
+func (c *fakeController) SetBlackout(blackout bool) {
+	c.stats.Blackout = blackout
+}
+
+func (c *fakeController) SetMaster(master float64) error {
+	if master < 0 || master > 1 {
+		return fmt.Errorf("invalid master intensity (master=%v)", master)
+	}
+	c.stats.Master = master
+	c.stats.MasterTarget = master
+
+	return nil
+}
+
+func (c *fakeController) SetMode(mode string, fade time.Duration) error {
+	if mode != ambilight.ModeAmbilight && mode != "warm" {
+		return fmt.Errorf("unknown mode: %s", mode)
+	}
+	c.stats.Mode = mode
+
+	return nil
+}
+
+func (c *fakeController) Modes() []string {
+	return []string{ambilight.ModeAmbilight, "warm"}
+}
+
+func TestBridgeCommands(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Stats   *ambilight.Stats
+		Topic   string
+		Payload string
+
+		ExpectedStats    ambilight.Stats
+		ExpectedRetained map[string]string
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			broker := newFakeBroker()
+			controller := &fakeController{
+				stats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+			}
+			if tc.Stats != nil {
+				controller.stats = *tc.Stats
+			}
+			b := NewBridge(broker, controller, nil, Config{Prefix: "living", DiscoveryPrefix: "-"}, nil)
+			require.NoError(t, b.Start())
+
+			require.NoError(t, broker.Publish(tc.Topic, []byte(tc.Payload), false))
+
+			assert.Equal(t, tc.ExpectedStats, controller.stats)
+			for topic, payload := range tc.ExpectedRetained {
+				assert.Equal(t, payload, broker.retained[topic], topic)
+			}
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Disable",
+
+		Topic:   "living/enable/set",
+		Payload: "OFF",
+
+		ExpectedStats: ambilight.Stats{Running: true, Paused: true, Blackout: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/status": "online",
+			"living/enable": "OFF",
+			"living/mode":   "blackout",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Enable",
+
+		Stats:   &ambilight.Stats{Running: true, Paused: true, Blackout: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		Topic:   "living/enable/set",
+		Payload: "ON",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/enable": "ON",
+			"living/mode":   "ambilight",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Brightness",
+
+		Topic:   "living/brightness/set",
+		Payload: "40",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 0.4, MasterTarget: 0.4, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/enable":     "ON",
+			"living/brightness": "40",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Invalid Brightness",
+
+		Topic:   "living/brightness/set",
+		Payload: "200",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/brightness": "100",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Blackout",
+
+		Topic:   "living/mode/set",
+		Payload: "blackout",
+
+		ExpectedStats: ambilight.Stats{Running: true, Blackout: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/mode": "blackout",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Preset",
+
+		Topic:   "living/mode/set",
+		Payload: "warm",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: "warm"},
+		ExpectedRetained: map[string]string{
+			"living/mode": "warm",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Scene",
+
+		Stats:   &ambilight.Stats{Running: true, Blackout: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		Topic:   "living/scene/set",
+		Payload: "warm",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: "warm"},
+		ExpectedRetained: map[string]string{
+			"living/mode": "warm",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Not A Scene",
+
+		Topic:   "living/scene/set",
+		Payload: "ambilight",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/mode": "ambilight",
+		},
+	})
+	validate(t, &testCase{
+		Name: "Unknown Mode",
+
+		Topic:   "living/mode/set",
+		Payload: "disco",
+
+		ExpectedStats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+		ExpectedRetained: map[string]string{
+			"living/mode": "ambilight",
+		},
+	})
+}
+
+func TestBridgeObserve(t *testing.T) {
+	broker := newFakeBroker()
+	controller := &fakeController{
+		stats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+	}
+	left := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	unnamed := &image.Rectangle{Max: image.Point{X: 5, Y: 5}}
+	b := NewBridge(broker, controller, map[*image.Rectangle]string{left: "left edge"}, Config{DiscoveryPrefix: "-"}, nil)
+	require.NoError(t, b.Start())
+	broker.published = nil
+
+	now := time.Unix(1, 0)
+	frame := &ambilight.Frame{
+		Time: now,
+		Colors: map[*image.Rectangle]color.RGBA64{
+			left:    {R: 0xff00, G: 0x8000, B: 0x00ff, A: 0xffff},
+			unnamed: {A: 0xffff},
+		},
+	}
+	b.publishFrame(frame)
+	assert.Equal(t, []string{"screentoartnet/areas/left_edge/color 255,128,0"}, broker.published)
+
+	// Colors are throttled, state changes are not.
+	controller.stats.Paused = true
+	frame.Time = now.Add(500 * time.Millisecond)
+	b.publishFrame(frame)
+	assert.Len(t, broker.published, 4)
+	assert.Equal(t, "OFF", broker.retained["screentoartnet/enable"])
+
+	frame.Time = now.Add(time.Second)
+	b.publishFrame(frame)
+	assert.Len(t, broker.published, 5)
+}
+
+func TestBridgeObserveSlowBroker(t *testing.T) {
+	broker := newFakeBroker()
+	controller := &fakeController{
+		stats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 1, Mode: ambilight.ModeAmbilight},
+	}
+	left := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	b := NewBridge(broker, controller, map[*image.Rectangle]string{left: "left"}, Config{DiscoveryPrefix: "-"}, nil)
+	require.NoError(t, b.Start())
+
+	gate := make(chan struct{})
+	broker.lock.Lock()
+	broker.gate = gate
+	broker.lock.Unlock()
+
+	// The frames are dropped instead of waiting for the broker.
+	observed := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			b.Observe(&ambilight.Frame{
+				Time:   time.Unix(int64(i), 0),
+				Colors: map[*image.Rectangle]color.RGBA64{left: {R: 0xff00, A: 0xffff}},
+			})
+		}
+		close(observed)
+	}()
+	select {
+	case <-observed:
+	case <-time.After(time.Second):
+		t.Fatal("observing blocked on the broker")
+	}
+
+	close(gate)
+	assert.Eventually(t, func() bool {
+		broker.lock.Lock()
+		defer broker.lock.Unlock()
+
+		return broker.retained["screentoartnet/enable"] == "ON" && len(broker.published) > 0 && broker.published[len(broker.published)-1] == "screentoartnet/areas/left/color 255,0,0"
+	}, time.Second, time.Millisecond)
+}
+
+func TestBridgeDiscovery(t *testing.T) {
+	broker := newFakeBroker()
+	left := &image.Rectangle{Max: image.Point{X: 10, Y: 10}}
+	b := NewBridge(broker, &fakeController{}, map[*image.Rectangle]string{left: "left"}, Config{NodeID: "tv"}, nil)
+	require.NoError(t, b.Start())
+
+	var light map[string]any
+	require.NoError(t, json.Unmarshal([]byte(broker.retained["homeassistant/light/tv/ambilight/config"]), &light))
+	assert.Equal(t, "tv_ambilight", light["unique_id"])
+	assert.Equal(t, "screentoartnet/enable/set", light["command_topic"])
+	assert.Equal(t, "screentoartnet/brightness/set", light["brightness_command_topic"])
+	assert.Equal(t, []any{"ambilight", "warm", "blackout"}, light["effect_list"])
+	assert.Equal(t, "screentoartnet/status", light["availability_topic"])
+
+	var scene map[string]any
+	require.NoError(t, json.Unmarshal([]byte(broker.retained["homeassistant/scene/tv/warm/config"]), &scene))
+	assert.Equal(t, "tv_scene_warm", scene["unique_id"])
+	assert.Equal(t, "screentoartnet/scene/set", scene["command_topic"])
+	assert.Equal(t, "warm", scene["payload_on"])
+	assert.NotContains(t, broker.retained, "homeassistant/scene/tv/ambilight/config")
+
+	var sensor map[string]any
+	require.NoError(t, json.Unmarshal([]byte(broker.retained["homeassistant/sensor/tv/left/config"]), &sensor))
+	assert.Equal(t, "tv_area_left", sensor["unique_id"])
+	assert.Equal(t, "screentoartnet/areas/left/color", sensor["state_topic"])
+
+	right := &image.Rectangle{Min: image.Point{X: 10}, Max: image.Point{X: 20, Y: 10}}
+	require.NoError(t, b.SetAreaNames(map[*image.Rectangle]string{right: "right"}))
+	assert.Contains(t, broker.retained, "homeassistant/sensor/tv/right/config")
+	assert.Equal(t, "", broker.retained["homeassistant/sensor/tv/left/config"])
+	assert.Contains(t, broker.published, "homeassistant/sensor/tv/left/config ")
+	assert.NotEqual(t, "", broker.retained["homeassistant/light/tv/ambilight/config"])
+}
+
+func TestBridgeReconnect(t *testing.T) {
+	broker := newFakeBroker()
+	controller := &fakeController{
+		stats: ambilight.Stats{Running: true, Master: 1, MasterTarget: 0.5, Mode: "warm"},
+	}
+	b := NewBridge(broker, controller, nil, Config{NodeID: "tv"}, nil)
+	require.NoError(t, b.Start())
+
+	broker.reconnect("screentoartnet/status")
+
+	assert.Equal(t, "online", broker.retained["screentoartnet/status"])
+	assert.Equal(t, "ON", broker.retained["screentoartnet/enable"])
+	assert.Equal(t, "50", broker.retained["screentoartnet/brightness"])
+	assert.Equal(t, "warm", broker.retained["screentoartnet/mode"])
+	assert.Contains(t, broker.retained, "homeassistant/light/tv/ambilight/config")
+}
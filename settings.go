@@ -0,0 +1,120 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+)
+
+// settingFlags maps the flags of the run settings to their values in the configuration.
+var settingFlags = map[string]func(s *ambilight.Settings) (string, bool){
+	"src":         func(s *ambilight.Settings) (string, bool) { return optional(s.Src) },
+	"dst":         func(s *ambilight.Settings) (string, bool) { return optional(s.Dst) },
+	"pause":       func(s *ambilight.Settings) (string, bool) { return optional(s.Pause) },
+	"screen":      func(s *ambilight.Settings) (string, bool) { return optional(s.Screen) },
+	"spacing":     func(s *ambilight.Settings) (string, bool) { return optional(s.Spacing) },
+	"threshold":   func(s *ambilight.Settings) (string, bool) { return optional(s.Threshold) },
+	"reducer":     func(s *ambilight.Settings) (string, bool) { return optional(s.Reducer) },
+	"log-level":   func(s *ambilight.Settings) (string, bool) { return optional(s.LogLevel) },
+	"log-format":  func(s *ambilight.Settings) (string, bool) { return optional(s.LogFormat) },
+	"metrics":     func(s *ambilight.Settings) (string, bool) { return optional(s.Metrics) },
+	"web":         func(s *ambilight.Settings) (string, bool) { return optional(s.Web) },
+	"api":         func(s *ambilight.Settings) (string, bool) { return optional(s.API) },
+	"mqtt":        func(s *ambilight.Settings) (string, bool) { return optional(s.MQTT) },
+	"mqtt-prefix": func(s *ambilight.Settings) (string, bool) { return optional(s.MQTTPrefix) },
+	"mqtt-fade":   func(s *ambilight.Settings) (string, bool) { return optional(s.MQTTFade) },
+}
+
+// optional returns the configured value as flag value.
+func optional[T any](value *T) (string, bool) {
+	if value == nil {
+		return "", false
+	}
+
+	return fmt.Sprint(*value), true
+}
+
+// settingEnv returns the environment variable of the setting with the given flag name, e.g. SCREENTOARTNET_MQTT_PREFIX.
+func settingEnv(name string) string {
+	return "SCREENTOARTNET_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+const (
+	// sourceDefault identifies a setting with its default value.
+	sourceDefault = "default"
+	// sourceConfig identifies a setting from the configuration.
+	sourceConfig = "config"
+	// sourceEnv identifies a setting from an environment variable.
+	sourceEnv = "env"
+	// sourceFlag identifies a setting from a command line flag.
+	sourceFlag = "flag"
+)
+
+// applySettings sets the flags of the run settings that were not given on the command line, preferring environment variables over the configuration.
+// It returns the source of every setting of the flag set by flag name.
+func applySettings(flags *flag.FlagSet, settings *ambilight.Settings, getenv func(string) string) (sources map[string]string, err error) {
+	sources = map[string]string{}
+	flags.Visit(func(f *flag.Flag) {
+		if _, ok := settingFlags[f.Name]; ok {
+			sources[f.Name] = sourceFlag
+		}
+	})
+
+	for _, name := range settingNames() {
+		// Commands only have the flags of the settings they use.
+		if flags.Lookup(name) == nil || sources[name] == sourceFlag {
+			continue
+		}
+
+		if value := getenv(settingEnv(name)); value != "" {
+			if err := flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid environment variable %s: %v", settingEnv(name), err)
+			}
+			sources[name] = sourceEnv
+		} else if value, ok := settingFlags[name](settings); ok {
+			if err := flags.Set(name, value); err != nil {
+				return nil, fmt.Errorf("invalid setting %s: %v", name, err)
+			}
+			sources[name] = sourceConfig
+		} else {
+			sources[name] = sourceDefault
+		}
+	}
+
+	return sources, nil
+}
+
+// settingNames returns the flag names of the run settings in ascending order.
+func settingNames() []string {
+	names := make([]string, 0, len(settingFlags))
+	for name := range settingFlags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// logSettings logs the effective run settings together with their sources, without the passwords of URLs.
+func logSettings(logger *slog.Logger, flags *flag.FlagSet, sources map[string]string) {
+	var attributes []any
+	for _, name := range settingNames() {
+		f := flags.Lookup(name)
+		if f == nil {
+			continue
+		}
+
+		value := f.Value.String()
+		if u, err := url.Parse(value); err == nil && u.User != nil {
+			value = u.Redacted()
+		}
+		attributes = append(attributes, slog.Group(name, "value", value, "source", sources[name]))
+	}
+
+	logger.Info("effective settings", attributes...)
+}
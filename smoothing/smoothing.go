@@ -0,0 +1,117 @@
+package smoothing
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+	"time"
+)
+
+// Config holds the configuration of a smoothing filter.
+type Config struct {
+	// TimeConstant holds the time constant of the exponential moving average in ms, 0 disables the averaging.
+	TimeConstant int
+	// MaxSpeed holds the maximum change of an 8-bit color channel per second, 0 disables the slew limiting.
+	MaxSpeed int
+	// SceneCut holds the 8-bit color channel difference at which the filter is bypassed, 0 disables the bypass.
+	SceneCut int
+}
+
+// Verify checks if the Config is a valid smoothing configuration.
+func (c *Config) Verify() error {
+	if c.TimeConstant < 0 {
+		return fmt.Errorf("invalid time constant for smoothing (%v)", c.TimeConstant)
+	}
+	if c.MaxSpeed < 0 {
+		return fmt.Errorf("invalid maximum speed for smoothing (%v)", c.MaxSpeed)
+	}
+	if c.SceneCut < 0 || c.SceneCut > 255 {
+		return fmt.Errorf("invalid scene cut threshold for smoothing (%v)", c.SceneCut)
+	}
+
+	return nil
+}
+
+// Filter smooths a stream of colors over time.
+type Filter struct {
+	// Config holds the filter configuration.
+	Config Config
+
+	// state holds the current filtered 16-bit color channels.
+	state [3]float64
+	// last holds the time of the last update.
+	last time.Time
+	// initialized is set after the first update.
+	initialized bool
+}
+
+// NewFilter returns a new filter with the given configuration.
+func NewFilter(config Config) *Filter {
+	return &Filter{
+		Config: config,
+	}
+}
+
+// Apply feeds the color observed at the given time into the filter and returns the smoothed color.
+func (f *Filter) Apply(c color.RGBA64, now time.Time) color.RGBA64 {
+	target := [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+
+	if !f.initialized || f.isSceneCut(target) {
+		f.state = target
+		f.last = now
+		f.initialized = true
+
+		return f.color()
+	}
+
+	dt := now.Sub(f.last).Seconds()
+	f.last = now
+	if dt < 0 {
+		dt = 0
+	}
+
+	for i := range f.state {
+		delta := target[i] - f.state[i]
+
+		if f.Config.TimeConstant > 0 {
+			delta *= 1 - math.Exp(-dt*1000/float64(f.Config.TimeConstant))
+		}
+
+		if f.Config.MaxSpeed > 0 {
+			limit := float64(f.Config.MaxSpeed) * 0x101 * dt
+			delta = math.Max(-limit, math.Min(limit, delta))
+		}
+
+		f.state[i] += delta
+	}
+
+	return f.color()
+}
+
+// Reset discards the filter state so the next color is passed through unchanged.
+func (f *Filter) Reset() {
+	f.initialized = false
+}
+
+func (f *Filter) isSceneCut(target [3]float64) bool {
+	if f.Config.SceneCut == 0 {
+		return false
+	}
+
+	for i := range f.state {
+		if math.Abs(target[i]-f.state[i]) >= float64(f.Config.SceneCut)*0x101 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (f *Filter) color() color.RGBA64 {
+	return color.RGBA64{
+		R: uint16(math.Round(f.state[0])),
+		G: uint16(math.Round(f.state[1])),
+		B: uint16(math.Round(f.state[2])),
+		A: 0xffff,
+	}
+}
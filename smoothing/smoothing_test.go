@@ -0,0 +1,156 @@
+package smoothing
+
+import (
+	"errors"
+	"image/color"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigVerify(t *testing.T) {
+	type testCase struct {
+		Name string
+
+		Config *Config
+		Error  error
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			err := tc.Config.Verify()
+			assert.Equal(t, tc.Error, err)
+		})
+	}
+
+	validate(t, &testCase{
+		Name: "Valid",
+
+		Config: &Config{
+			TimeConstant: 100,
+			MaxSpeed:     255,
+			SceneCut:     128,
+		},
+	})
+	validate(t, &testCase{
+		Name: "Time Constant",
+
+		Config: &Config{
+			TimeConstant: -1,
+		},
+		Error: errors.New("invalid time constant for smoothing (-1)"),
+	})
+	validate(t, &testCase{
+		Name: "Maximum Speed",
+
+		Config: &Config{
+			MaxSpeed: -1,
+		},
+		Error: errors.New("invalid maximum speed for smoothing (-1)"),
+	})
+	validate(t, &testCase{
+		Name: "Scene Cut",
+
+		Config: &Config{
+			SceneCut: 256,
+		},
+		Error: errors.New("invalid scene cut threshold for smoothing (256)"),
+	})
+}
+
+func TestFilterApply(t *testing.T) {
+	type step struct {
+		// Offset holds the time since the first step in ms.
+		Offset int
+		Input  color.RGBA64
+		Output color.RGBA64
+	}
+
+	type testCase struct {
+		Name string
+
+		Config Config
+		Steps  []step
+	}
+
+	validate := func(t *testing.T, tc *testCase) {
+		t.Run(tc.Name, func(t *testing.T) {
+			f := NewFilter(tc.Config)
+			start := time.Unix(0, 0)
+
+			for i, s := range tc.Steps {
+				actual := f.Apply(s.Input, start.Add(time.Duration(s.Offset)*time.Millisecond))
+				assert.Equal(t, s.Output, actual, "step %d", i)
+			}
+		})
+	}
+
+	gray := func(v uint16) color.RGBA64 {
+		return color.RGBA64{R: v, G: v, B: v, A: 0xffff}
+	}
+	black := gray(0)
+	white := gray(0xffff)
+
+	validate(t, &testCase{
+		Name: "Disabled",
+
+		Steps: []step{
+			{Offset: 0, Input: black, Output: black},
+			{Offset: 10, Input: white, Output: white},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Exponential Moving Average",
+
+		Config: Config{
+			TimeConstant: 100,
+		},
+		Steps: []step{
+			{Offset: 0, Input: black, Output: black},
+			// 65535 * (1 - e^-1)
+			{Offset: 100, Input: white, Output: gray(41426)},
+			// 65535 * (1 - e^-2)
+			{Offset: 200, Input: white, Output: gray(56666)},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Slew Limiting",
+
+		Config: Config{
+			MaxSpeed: 100,
+		},
+		Steps: []step{
+			{Offset: 0, Input: black, Output: black},
+			{Offset: 500, Input: white, Output: gray(50 * 0x101)},
+			{Offset: 1500, Input: white, Output: gray(150 * 0x101)},
+			{Offset: 2500, Input: black, Output: gray(50 * 0x101)},
+		},
+	})
+	validate(t, &testCase{
+		Name: "Scene Cut",
+
+		Config: Config{
+			MaxSpeed: 100,
+			SceneCut: 200,
+		},
+		Steps: []step{
+			{Offset: 0, Input: black, Output: black},
+			{Offset: 500, Input: color.RGBA64{R: 199 * 0x101, A: 0xffff}, Output: color.RGBA64{R: 50 * 0x101, A: 0xffff}},
+			{Offset: 1000, Input: white, Output: white},
+		},
+	})
+}
+
+func TestFilterReset(t *testing.T) {
+	f := NewFilter(Config{
+		MaxSpeed: 1,
+	})
+	start := time.Unix(0, 0)
+
+	f.Apply(color.RGBA64{A: 0xffff}, start)
+	f.Reset()
+
+	white := color.RGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0xffff}
+	assert.Equal(t, white, f.Apply(white, start.Add(time.Millisecond)))
+}
@@ -0,0 +1,327 @@
+package web
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+)
+
+// previewWidth holds the width of the downscaled screenshot in pixels.
+const previewWidth = 640
+
+//go:embed index.html
+var indexPage []byte
+
+// Screenshotter captures the whole screen.
+type Screenshotter interface {
+	// Screenshot returns a capture of the whole screen.
+	Screenshot() (*image.RGBA, error)
+	// Size returns the size of the screenshots without capturing.
+	Size() (image.Point, error)
+}
+
+// Server serves a page showing the live screen, the areas and their colors, and lets the areas be edited.
+type Server struct {
+	// configPath holds the path of the configuration file the areas are saved to.
+	configPath string
+	// logger holds the logger of the server.
+	logger *slog.Logger
+
+	// mux holds the routes of the server.
+	mux *http.ServeMux
+
+	// lock guards the fields below.
+	lock sync.Mutex
+
+	// screen holds the screen the screenshots are taken of.
+	screen Screenshotter
+
+	// areaNames holds the names of the screen areas.
+	areaNames map[*image.Rectangle]string
+	// deviceNames holds the names of the DMX devices.
+	deviceNames map[*dmx.Device]string
+
+	// areas holds the declared areas per name as last saved.
+	areas map[string]image.Rectangle
+	// offscreen holds the names of the areas captured from their own monitor, which are not on the screenshot and cannot be edited.
+	offscreen map[string]bool
+	// areaColors holds the current color per area name.
+	areaColors map[string]color.RGBA64
+	// deviceColors holds the current color per device name.
+	deviceColors map[string]color.RGBA64
+}
+
+// NewServer returns a new server for the given configuration file, its loaded configuration and screen.
+func NewServer(configPath string, config *ambilight.Configuration, screen Screenshotter, logger *slog.Logger) (*Server, error) {
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	areas, err := ambilight.ReadAreas(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{
+		configPath: configPath,
+		screen:     screen,
+		logger:     logger,
+
+		areaNames:   config.AreaNames,
+		deviceNames: config.DeviceNames,
+
+		mux: http.NewServeMux(),
+
+		areas:        areas,
+		offscreen:    offscreen(config),
+		areaColors:   map[string]color.RGBA64{},
+		deviceColors: map[string]color.RGBA64{},
+	}
+
+	s.mux.HandleFunc("/", s.serveIndex)
+	s.mux.HandleFunc("/screenshot.png", s.serveScreenshot)
+	s.mux.HandleFunc("/api/areas", s.serveAreas)
+	s.mux.HandleFunc("/api/colors", s.serveColors)
+
+	return s, nil
+}
+
+// SetConfiguration replaces the configuration and the screen after a reload, re-reading the declared areas.
+// The areas keep their last saved state if they cannot be read.
+func (s *Server) SetConfiguration(config *ambilight.Configuration, screen Screenshotter) error {
+	areas, err := ambilight.ReadAreas(s.configPath)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.screen = screen
+	s.areaNames = config.AreaNames
+	s.deviceNames = config.DeviceNames
+	s.offscreen = offscreen(config)
+	s.areaColors = map[string]color.RGBA64{}
+	s.deviceColors = map[string]color.RGBA64{}
+	if err != nil {
+		return err
+	}
+	s.areas = areas
+
+	return nil
+}
+
+// currentScreen returns the screen the screenshots are taken of.
+func (s *Server) currentScreen() Screenshotter {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return s.screen
+}
+
+// Observe records the colors of the given frame.
+func (s *Server) Observe(frame *ambilight.Frame) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for a, c := range frame.Colors {
+		if name, ok := s.areaNames[a]; ok {
+			s.areaColors[name] = c
+		}
+	}
+	for d, c := range frame.Devices {
+		if name, ok := s.deviceNames[d]; ok {
+			s.deviceColors[name] = c
+		}
+	}
+}
+
+// offscreen returns the names of the areas captured from their own monitor instead of the screenshot.
+func offscreen(config *ambilight.Configuration) map[string]bool {
+	names := map[string]bool{}
+	for a, c := range config.Capture {
+		if c.Monitor != nil {
+			names[config.AreaNames[a]] = true
+		}
+	}
+
+	return names
+}
+
+// ServeHTTP serves the page and its API.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) serveIndex(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(indexPage)
+}
+
+func (s *Server) serveScreenshot(w http.ResponseWriter, r *http.Request) {
+	screenshot, err := s.currentScreen().Screenshot()
+	if err != nil {
+		s.logger.Error("screenshot failed", "error", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "image/png")
+	w.Header().Set("Cache-Control", "no-store")
+	if err := png.Encode(w, downscale(screenshot, previewWidth)); err != nil {
+		s.logger.Error("encoding screenshot failed", "error", err)
+	}
+}
+
+// areasResponse holds the declared areas on the screenshot together with the screen size they refer to.
+type areasResponse struct {
+	// Screen holds the size of the screen.
+	Screen image.Point
+	// Areas holds the declared areas per name, areas captured from their own monitor are left out.
+	Areas map[string]image.Rectangle
+}
+
+func (s *Server) serveAreas(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		size, err := s.currentScreen().Size()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		response := areasResponse{
+			Screen: size,
+			Areas:  map[string]image.Rectangle{},
+		}
+		for name, a := range s.areas {
+			if !s.offscreen[name] {
+				response.Areas[name] = a
+			}
+		}
+		writeJSON(w, response)
+	case http.MethodPut:
+		var areas map[string]image.Rectangle
+		if err := json.NewDecoder(r.Body).Decode(&areas); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+
+			return
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+
+		for name, a := range areas {
+			if _, ok := s.areas[name]; !ok {
+				http.Error(w, fmt.Sprintf("unknown area: %s", name), http.StatusBadRequest)
+
+				return
+			}
+			if s.offscreen[name] {
+				http.Error(w, fmt.Sprintf("area is captured from its own monitor: %s", name), http.StatusBadRequest)
+
+				return
+			}
+			if a.Empty() {
+				http.Error(w, fmt.Sprintf("empty area: %s", name), http.StatusBadRequest)
+
+				return
+			}
+		}
+
+		updated := map[string]image.Rectangle{}
+		for name, a := range s.areas {
+			updated[name] = a
+		}
+		for name, a := range areas {
+			updated[name] = a.Canon()
+		}
+
+		if err := ambilight.WriteAreas(s.configPath, updated); err != nil {
+			s.logger.Error("saving areas failed", "path", s.configPath, "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+		s.areas = updated
+		s.logger.Info("areas saved", "path", s.configPath, "areas", len(areas))
+
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// colorsResponse holds the current colors as CSS hex colors.
+type colorsResponse struct {
+	// Areas holds the current color per area name.
+	Areas map[string]string
+	// Devices holds the current color per device name.
+	Devices map[string]string
+}
+
+func (s *Server) serveColors(w http.ResponseWriter, r *http.Request) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	response := colorsResponse{
+		Areas:   map[string]string{},
+		Devices: map[string]string{},
+	}
+	for name, c := range s.areaColors {
+		response.Areas[name] = hex(c)
+	}
+	for name, c := range s.deviceColors {
+		response.Devices[name] = hex(c)
+	}
+
+	writeJSON(w, response)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(v)
+}
+
+// hex returns the given color as 8-bit CSS hex color.
+func hex(c color.RGBA64) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R>>8, c.G>>8, c.B>>8)
+}
+
+// downscale returns the given image scaled to the given width by nearest neighbor sampling, keeping the aspect ratio.
+func downscale(src *image.RGBA, width int) *image.RGBA {
+	bounds := src.Bounds()
+	if bounds.Dx() <= width {
+		return src
+	}
+
+	height := bounds.Dy() * width / bounds.Dx()
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		sy := bounds.Min.Y + y*bounds.Dy()/height
+		for x := 0; x < width; x++ {
+			sx := bounds.Min.X + x*bounds.Dx()/width
+			dst.SetRGBA(x, y, src.RGBAAt(sx, sy))
+		}
+	}
+
+	return dst
+}
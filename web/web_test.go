@@ -0,0 +1,249 @@
+package web
+
+import (
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bauersimon/ScreenToArtNet/ambilight"
+	"github.com/bauersimon/ScreenToArtNet/dmx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeScreen struct {
+	size image.Point
+
+	// screenshots holds the number of calls to Screenshot.
+	screenshots int
+}
+
+func (s *fakeScreen) Screenshot() (*image.RGBA, error) {
+	s.screenshots++
+
+	return image.NewRGBA(image.Rectangle{Max: s.size}), nil
+}
+
+func (s *fakeScreen) Size() (image.Point, error) {
+	return s.size, nil
+}
+
+// newTestServer returns a server for a temporary configuration file and the relative path of that file.
+func newTestServer(t *testing.T) (*httptest.Server, *Server, string) {
+	config := `{
+	"Areas": {
+		"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 100, "Y": 1080}},
+		"right": {"Min": {"X": 1820, "Y": 0}, "Max": {"X": 1920, "Y": 1080}}
+	},
+	"Universes": {}
+}`
+	directory := t.TempDir()
+	require.NoError(t, ioutil.WriteFile(filepath.Join(directory, "config.json"), []byte(config), 0644))
+
+	cwd, err := os.Getwd()
+	require.NoError(t, err)
+	configPath, err := filepath.Rel(cwd, filepath.Join(directory, "config.json"))
+	require.NoError(t, err)
+
+	loaded, err := ambilight.ReadConfig(configPath)
+	require.NoError(t, err)
+
+	s, err := NewServer(configPath, loaded, &fakeScreen{size: image.Point{X: 1920, Y: 1080}}, nil)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+
+	return server, s, configPath
+}
+
+func TestServerIndex(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	response, err := server.Client().Get(server.URL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/html; charset=utf-8", response.Header.Get("Content-Type"))
+	body, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "<title>ScreenToArtNet</title>")
+}
+
+func TestServerScreenshot(t *testing.T) {
+	server, _, _ := newTestServer(t)
+
+	response, err := server.Client().Get(server.URL + "/screenshot.png")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, "image/png", response.Header.Get("Content-Type"))
+	screenshot, err := png.Decode(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 640, 360), screenshot.Bounds())
+}
+
+func TestServerAreas(t *testing.T) {
+	server, s, configPath := newTestServer(t)
+
+	response, err := server.Client().Get(server.URL + "/api/areas")
+	require.NoError(t, err)
+	var actual areasResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&actual))
+	response.Body.Close()
+	assert.Equal(t, areasResponse{
+		Screen: image.Point{X: 1920, Y: 1080},
+		Areas: map[string]image.Rectangle{
+			"left":  image.Rect(0, 0, 100, 1080),
+			"right": image.Rect(1820, 0, 1920, 1080),
+		},
+	}, actual)
+	assert.Equal(t, 0, s.screen.(*fakeScreen).screenshots)
+
+	put := func(body string) *http.Response {
+		request, err := http.NewRequest(http.MethodPut, server.URL+"/api/areas", strings.NewReader(body))
+		require.NoError(t, err)
+		response, err := server.Client().Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+
+		return response
+	}
+
+	assert.Equal(t, http.StatusBadRequest, put(`{"center": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 1, "Y": 1}}}`).StatusCode)
+	assert.Equal(t, http.StatusBadRequest, put(`{"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 0, "Y": 0}}}`).StatusCode)
+	assert.Equal(t, http.StatusNoContent, put(`{"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 200, "Y": 1080}}}`).StatusCode)
+
+	areas, err := ambilight.ReadAreas(configPath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]image.Rectangle{
+		"left":  image.Rect(0, 0, 200, 1080),
+		"right": image.Rect(1820, 0, 1920, 1080),
+	}, areas)
+
+	_, err = ambilight.ReadConfig(configPath)
+	assert.NoError(t, err)
+}
+
+func TestServerSetConfiguration(t *testing.T) {
+	server, s, configPath := newTestServer(t)
+
+	config := `{
+	"Areas": {
+		"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 100, "Y": 720}}
+	},
+	"Universes": {}
+}`
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+	loaded, err := ambilight.ReadConfig(configPath)
+	require.NoError(t, err)
+	assert.NoError(t, s.SetConfiguration(loaded, &fakeScreen{size: image.Point{X: 1280, Y: 720}}))
+
+	// The areas and the screenshots follow the reloaded configuration and screen.
+	response, err := server.Client().Get(server.URL + "/api/areas")
+	require.NoError(t, err)
+	var actual areasResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&actual))
+	response.Body.Close()
+	assert.Equal(t, areasResponse{
+		Screen: image.Point{X: 1280, Y: 720},
+		Areas: map[string]image.Rectangle{
+			"left": image.Rect(0, 0, 100, 720),
+		},
+	}, actual)
+
+	response, err = server.Client().Get(server.URL + "/screenshot.png")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	screenshot, err := png.Decode(response.Body)
+	require.NoError(t, err)
+	assert.Equal(t, image.Rect(0, 0, 640, 360), screenshot.Bounds())
+	assert.Equal(t, 1, s.currentScreen().(*fakeScreen).screenshots)
+}
+
+func TestServerAreasOffscreen(t *testing.T) {
+	config := `{
+	"Areas": {
+		"left": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 100, "Y": 1080}},
+		"desktop": {"Min": {"X": 1820, "Y": 0}, "Max": {"X": 2020, "Y": 1080}}
+	},
+	"Capture": {
+		"desktop": {"Monitor": "all"}
+	},
+	"Universes": {}
+}`
+	configPath := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, ioutil.WriteFile(configPath, []byte(config), 0644))
+	loaded, err := ambilight.ReadConfig(configPath)
+	require.NoError(t, err)
+	s, err := NewServer(configPath, loaded, &fakeScreen{size: image.Point{X: 1920, Y: 1080}}, nil)
+	require.NoError(t, err)
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	response, err := server.Client().Get(server.URL + "/api/areas")
+	require.NoError(t, err)
+	var actual areasResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&actual))
+	response.Body.Close()
+	assert.Equal(t, map[string]image.Rectangle{
+		"left": image.Rect(0, 0, 100, 1080),
+	}, actual.Areas)
+
+	request, err := http.NewRequest(http.MethodPut, server.URL+"/api/areas", strings.NewReader(`{"desktop": {"Min": {"X": 0, "Y": 0}, "Max": {"X": 200, "Y": 1080}}}`))
+	require.NoError(t, err)
+	response, err = server.Client().Do(request)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, "area is captured from its own monitor: desktop\n", string(body))
+}
+
+func TestServerColors(t *testing.T) {
+	server, s, _ := newTestServer(t)
+
+	var left *image.Rectangle
+	for a, name := range s.areaNames {
+		if name == "left" {
+			left = a
+		}
+	}
+	device := &dmx.Device{}
+	s.deviceNames = map[*dmx.Device]string{device: "par"}
+
+	s.Observe(&ambilight.Frame{
+		Colors: map[*image.Rectangle]color.RGBA64{
+			left:               {R: 0xff00, G: 0x8000, B: 0x00ff, A: 0xffff},
+			&image.Rectangle{}: {A: 0xffff},
+		},
+		Devices: map[*dmx.Device]color.RGBA64{
+			device: {R: 0x0100, A: 0xffff},
+		},
+	})
+
+	response, err := server.Client().Get(server.URL + "/api/colors")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	var actual colorsResponse
+	assert.NoError(t, json.NewDecoder(response.Body).Decode(&actual))
+	assert.Equal(t, colorsResponse{
+		Areas: map[string]string{
+			"left": "#ff8000",
+		},
+		Devices: map[string]string{
+			"par": "#010000",
+		},
+	}, actual)
+}